@@ -0,0 +1,208 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/70k10/beat-exporter/collector"
+	"github.com/70k10/beat-exporter/internal/config"
+)
+
+// apiTargetSource identifies targets added through the admin API in the target manager
+const apiTargetSource = "api"
+
+// adminAPI manages the targets of the exporter at runtime, requests need the bearer token from tokenFile
+type adminAPI struct {
+	mtx            sync.RWMutex
+	manager        *targetManager
+	tokenFile      string
+	defaultTimeout time.Duration
+}
+
+// apiTarget target as accepted by POST /api/v1/targets
+type apiTarget struct {
+	URI     string            `json:"uri"`
+	Label   string            `json:"label,omitempty"`
+	Timeout string            `json:"timeout,omitempty"`
+	Labels  map[string]string `json:"labels,omitempty"`
+}
+
+// apiTargetStatus target as returned by GET /api/v1/targets
+type apiTargetStatus struct {
+	Source     string                 `json:"source"`
+	URI        string                 `json:"uri"`
+	Collector  string                 `json:"collector"`
+	Exported   bool                   `json:"exported"`
+	Timeout    string                 `json:"timeout"`
+	Labels     map[string]string      `json:"labels,omitempty"`
+	BeatInfo   collector.BeatInfo     `json:"beat_info"`
+	LastScrape collector.ScrapeStatus `json:"last_scrape"`
+}
+
+func newAdminAPI(manager *targetManager, tokenFile string) *adminAPI {
+	return &adminAPI{
+		manager:        manager,
+		tokenFile:      tokenFile,
+		defaultTimeout: config.DefaultTimeout,
+	}
+}
+
+// SetConfig sets the timeout of targets added without one
+func (a *adminAPI) SetConfig(cfg *config.Config) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	a.defaultTimeout = cfg.Global.Timeout
+}
+
+// TargetsHandler serves GET, POST and DELETE on the target list
+func (a *adminAPI) TargetsHandler(w http.ResponseWriter, r *http.Request) {
+	status, err := a.authorize(r)
+	if err != nil {
+		if status == http.StatusUnauthorized {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+		}
+		writeAPIError(w, status, err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		a.listTargets(w)
+	case http.MethodPost:
+		a.addTarget(w, r)
+	case http.MethodDelete:
+		a.removeTarget(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		writeAPIError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+	}
+}
+
+// authorize checks the bearer token of r, the API is forbidden while the token file is unreadable or empty
+func (a *adminAPI) authorize(r *http.Request) (int, error) {
+	// the token file is read on every request so it can be rotated without a restart
+	token, err := readAdminToken(a.tokenFile)
+	if err != nil {
+		log.WithFields(log.Fields{"err": err}).Errorf("Failed to read admin token file: %v", err)
+		return http.StatusForbidden, fmt.Errorf("admin token unavailable")
+	}
+
+	expected := "Bearer " + token
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(expected)) != 1 {
+		return http.StatusUnauthorized, fmt.Errorf("invalid or missing bearer token")
+	}
+
+	return http.StatusOK, nil
+}
+
+// readAdminToken returns the token in tokenFile, an empty token would accept "Bearer " and is an error
+func readAdminToken(tokenFile string) (string, error) {
+	content, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return "", err
+	}
+
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", tokenFile)
+	}
+
+	return token, nil
+}
+
+func (a *adminAPI) listTargets(w http.ResponseWriter) {
+	targets := []apiTargetStatus{}
+	for _, target := range a.manager.Targets() {
+		targets = append(targets, apiTargetStatus{
+			Source:     target.Source,
+			URI:        target.Target.URI,
+			Collector:  target.CollectorLabel,
+			Exported:   target.Exported,
+			Timeout:    target.Target.Timeout.String(),
+			Labels:     target.Target.Labels,
+			BeatInfo:   target.BeatInfo,
+			LastScrape: target.ScrapeStatus,
+		})
+	}
+
+	writeAPIResponse(w, http.StatusOK, targets)
+}
+
+func (a *adminAPI) addTarget(w http.ResponseWriter, r *http.Request) {
+	request := apiTarget{}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+		return
+	}
+
+	a.mtx.RLock()
+	target := config.Target{
+		URI:     request.URI,
+		Label:   request.Label,
+		Timeout: a.defaultTimeout,
+		Labels:  request.Labels,
+	}
+	a.mtx.RUnlock()
+
+	if request.Timeout != "" {
+		target.Timeout, err = time.ParseDuration(request.Timeout)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid timeout: %v", err))
+			return
+		}
+	}
+
+	err = target.Validate()
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = a.manager.Add(apiTargetSource, target)
+	if errors.Is(err, errTargetExists) || errors.Is(err, errLabelInUse) {
+		writeAPIError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	writeAPIResponse(w, http.StatusCreated, request)
+}
+
+func (a *adminAPI) removeTarget(w http.ResponseWriter, r *http.Request) {
+	collectorLabel := r.URL.Query().Get("collector")
+	if collectorLabel == "" {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("collector parameter is missing"))
+		return
+	}
+
+	if !a.manager.Remove(apiTargetSource, collectorLabel) {
+		writeAPIError(w, http.StatusNotFound, fmt.Errorf("no target with collector %q added through the API", collectorLabel))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeAPIResponse(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	writeAPIResponse(w, status, map[string]string{"error": err.Error()})
+}
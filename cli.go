@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/70k10/beat-exporter/collector"
+)
+
+// jsonMetricFamily metric family as printed by the scrape subcommand with -format json
+type jsonMetricFamily struct {
+	Name    string       `json:"name"`
+	Help    string       `json:"help"`
+	Type    string       `json:"type"`
+	Metrics []jsonMetric `json:"metrics"`
+}
+
+type jsonMetric struct {
+	Labels map[string]string `json:"labels"`
+	Value  float64           `json:"value"`
+}
+
+// runCheckConfig validates the configuration file, the -beat.uri targets and the metric mappings, it returns the exit code
+func runCheckConfig(args []string, configFile string, beatURI string, beatTimeout time.Duration, mappings mappingFlags) int {
+	if len(args) != 0 {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] check-config\n", os.Args[0])
+		return 2
+	}
+
+	cfg, err := loadConfig(configFile, beatURI, beatTimeout)
+	if err == nil {
+		// targets from -beat.uri are not validated by the configuration file
+		for i, target := range cfg.Targets {
+			err = target.Validate()
+			if err != nil {
+				err = fmt.Errorf("targets[%d]: %v", i, err)
+				break
+			}
+		}
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration is invalid: %v\n", err)
+		return 1
+	}
+
+	metricMappings, err := mappings.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Metric mappings are invalid: %v\n", err)
+		return 1
+	}
+
+	err = cfg.CheckLabels(metricMappings.LabelNames())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration is invalid: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("Configuration is valid: %d targets, %d modules, %d discovery configurations\n",
+		len(cfg.Targets), len(cfg.Modules),
+		len(cfg.Discovery.File)+len(cfg.Discovery.Docker)+len(cfg.Discovery.Kubernetes)+len(cfg.Discovery.BeatConfig))
+
+	return 0
+}
+
+// runScrape collects the metrics of a single beat once and prints them, it returns the exit code
+func runScrape(args []string, name string, configFile string, beatURI string, beatTimeout time.Duration, mappings mappingFlags) int {
+	flags := flag.NewFlagSet("scrape", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s [flags] scrape [scrape flags] <uri>\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	format := flags.String("format", "text", "Output format, text for the Prometheus text format or json.")
+	module := flags.String("module", "", "Module of -config.file with the timeout, authentication and TLS settings of the beat.")
+	label := flags.String("label", "", "Collector label, host:port by default.")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return 2
+	}
+
+	if flags.NArg() != 1 || (*format != "text" && *format != "json") {
+		flags.Usage()
+		return 2
+	}
+
+	cfg, err := loadConfig(configFile, beatURI, beatTimeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return 1
+	}
+
+	metricMappings, err := mappings.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load metric mappings: %v\n", err)
+		return 1
+	}
+
+	prober := newProbeHandler(name, metricMappings)
+	prober.SetConfig(cfg)
+
+	target, err := prober.probeTarget(flags.Arg(0), *module)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid target: %v\n", err)
+		return 2
+	}
+
+	httpClient, parsedURL, err := newTargetClient(target)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid target: %v\n", err)
+		return 2
+	}
+
+	_, beatCollector := collector.NewMainCollector(httpClient, parsedURL, name, *label, nil, collector.LabelTemplates{}, collector.Polling{}, metricMappings)
+	defer beatCollector.Stop()
+
+	select {
+	case <-beatCollector.Detected():
+	case <-time.After(target.Timeout):
+		log.WithFields(log.Fields{"URI": target.URI}).Error("Timed out detecting beat type")
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(beatCollector)
+
+	families, err := registry.Gather()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to gather metrics: %v\n", err)
+		return 1
+	}
+
+	if *format == "json" {
+		err = writeJSONMetrics(os.Stdout, families)
+	} else {
+		encoder := expfmt.NewEncoder(os.Stdout, expfmt.FmtText)
+		for _, family := range families {
+			err = encoder.Encode(family)
+			if err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to print metrics: %v\n", err)
+		return 1
+	}
+
+	status := beatCollector.GetScrapeStatus()
+	if !status.Success {
+		fmt.Fprintf(os.Stderr, "Scrape failed: %s\n", status.Error)
+		return 1
+	}
+
+	return 0
+}
+
+func writeJSONMetrics(w io.Writer, families []*dto.MetricFamily) error {
+	output := make([]jsonMetricFamily, 0, len(families))
+	for _, family := range families {
+		jsonFamily := jsonMetricFamily{
+			Name: family.GetName(),
+			Help: family.GetHelp(),
+			Type: strings.ToLower(family.GetType().String()),
+		}
+
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string, len(metric.GetLabel()))
+			for _, pair := range metric.GetLabel() {
+				labels[pair.GetName()] = pair.GetValue()
+			}
+
+			value := metric.GetUntyped().GetValue()
+			switch {
+			case metric.GetCounter() != nil:
+				value = metric.GetCounter().GetValue()
+			case metric.GetGauge() != nil:
+				value = metric.GetGauge().GetValue()
+			}
+
+			jsonFamily.Metrics = append(jsonFamily.Metrics, jsonMetric{Labels: labels, Value: value})
+		}
+
+		output = append(output, jsonFamily)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(output)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/70k10/beat-exporter/internal/config"
+)
+
+// newTargetClient builds the http client and base URL used to query a target
+func newTargetClient(target config.Target) (*http.Client, *url.URL, error) {
+	parsedURL, err := url.Parse(target.URI)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	httpClient := &http.Client{
+		Timeout: target.Timeout,
+	}
+
+	if parsedURL.Scheme == "unix" {
+		unixPath := parsedURL.Path
+		parsedURL.Scheme = "http"
+		parsedURL.Host = "localhost"
+		parsedURL.Path = ""
+		httpClient.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", unixPath)
+			},
+		}
+	} else if target.TLS != (config.TLSConfig{}) {
+		tlsConfig, err := newTLSConfig(target.TLS, parsedURL.Hostname())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		httpClient.Transport = &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig,
+		}
+	}
+
+	withAuth(httpClient, target.Auth)
+
+	return httpClient, parsedURL, nil
+}
+
+// withAuth wraps the transport of client to send the configured credentials and headers
+func withAuth(client *http.Client, auth config.Auth) {
+	if auth.BasicAuth == nil && auth.BearerToken == "" && auth.BearerTokenFile == "" && len(auth.Headers) == 0 {
+		return
+	}
+
+	next := client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	rt := &authRoundTripper{auth: auth, next: next}
+	if auth.BearerTokenFile != "" {
+		rt.bearerTokenFile = &secretFile{path: auth.BearerTokenFile}
+	}
+	if auth.BasicAuth != nil && auth.BasicAuth.PasswordFile != "" {
+		rt.passwordFile = &secretFile{path: auth.BasicAuth.PasswordFile}
+	}
+
+	client.Transport = rt
+}
+
+type authRoundTripper struct {
+	auth            config.Auth
+	bearerTokenFile *secretFile
+	passwordFile    *secretFile
+	next            http.RoundTripper
+}
+
+// RoundTrip sets the authorization and custom headers on a copy of the request
+func (rt *authRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+
+	for name, value := range rt.auth.Headers {
+		if http.CanonicalHeaderKey(name) == "Host" {
+			req.Host = value
+			continue
+		}
+		req.Header.Set(name, value)
+	}
+
+	if rt.auth.BasicAuth != nil {
+		password := rt.auth.BasicAuth.Password
+		if rt.passwordFile != nil {
+			var err error
+			password, err = rt.passwordFile.Read()
+			if err != nil {
+				return nil, err
+			}
+		}
+		req.SetBasicAuth(rt.auth.BasicAuth.Username, password)
+	}
+
+	bearerToken := rt.auth.BearerToken
+	if rt.bearerTokenFile != nil {
+		var err error
+		bearerToken, err = rt.bearerTokenFile.Read()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+bearerToken)
+	}
+
+	return rt.next.RoundTrip(req)
+}
+
+// secretFile caches the content of a credential file, re-reading it when its modification time or size changes
+type secretFile struct {
+	path    string
+	mtx     sync.Mutex
+	modTime time.Time
+	size    int64
+	content string
+}
+
+// Read returns the content of the file without surrounding whitespace
+func (f *secretFile) Read() (string, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return "", err
+	}
+
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	if info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.content, nil
+	}
+
+	content, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return "", err
+	}
+
+	f.content = strings.TrimSpace(string(content))
+	f.modTime = info.ModTime()
+	f.size = info.Size()
+
+	return f.content, nil
+}
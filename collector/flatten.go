@@ -0,0 +1,170 @@
+package collector
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var invalidMetricNameRE = regexp.MustCompile(`[^a-zA-Z0-9_:]`)
+
+// Flattening exports every numeric value of the stats that no mapping exports as an untyped metric named after
+// its path, e.g. libbeat.output.events.total as <beat>_libbeat_output_events_total. Allow and Deny are path globs,
+// * matches a single key and ** any number of keys. Without Allow all paths are allowed.
+type Flattening struct {
+	Allow []string
+	Deny  []string
+
+	allow [][]string
+	deny  [][]string
+}
+
+// SetFlattening enables the flattening of the stats in addition to the mappings
+func (m *Mappings) SetFlattening(flattening Flattening) error {
+	var err error
+
+	flattening.allow, err = compileGlobs(flattening.Allow)
+	if err != nil {
+		return err
+	}
+	flattening.deny, err = compileGlobs(flattening.Deny)
+	if err != nil {
+		return err
+	}
+
+	m.flattening = &flattening
+	return nil
+}
+
+func compileGlobs(globs []string) ([][]string, error) {
+	var compiled [][]string
+	for _, glob := range globs {
+		segments, err := splitPath(glob)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, segment := range segments {
+			_, err = path.Match(segment, "")
+			if err != nil {
+				return nil, fmt.Errorf("invalid path glob %q: %v", glob, err)
+			}
+		}
+		compiled = append(compiled, segments)
+	}
+
+	return compiled, nil
+}
+
+// matchGlob reports whether the path segments match the glob segments
+func matchGlob(glob []string, segments []string) bool {
+	if len(glob) == 0 {
+		return len(segments) == 0
+	}
+
+	if glob[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchGlob(glob[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(segments) == 0 {
+		return false
+	}
+
+	matched, _ := path.Match(glob[0], segments[0])
+	return matched && matchGlob(glob[1:], segments[1:])
+}
+
+func (f *Flattening) exports(segments []string) bool {
+	allowed := len(f.allow) == 0
+	for _, glob := range f.allow {
+		if matchGlob(glob, segments) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return false
+	}
+
+	for _, glob := range f.deny {
+		if matchGlob(glob, segments) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// flattenCollector exports the numeric values of the stats left out by the mappings of a beat
+type flattenCollector struct {
+	flattening  *Flattening
+	namespace   string
+	constLabels prometheus.Labels
+	mapped      []*MetricMapping
+	mappedNames map[string]bool
+}
+
+func newFlattenCollector(flattening *Flattening, namespace string, constLabels prometheus.Labels, mapped []*MetricMapping) *flattenCollector {
+	collector := &flattenCollector{
+		flattening:  flattening,
+		namespace:   namespace,
+		constLabels: constLabels,
+		mapped:      mapped,
+		mappedNames: make(map[string]bool, len(mapped)),
+	}
+	for _, mapping := range mapped {
+		collector.mappedNames[prometheus.BuildFQName(namespace, "", mapping.Name)] = true
+	}
+
+	return collector
+}
+
+// Describe sends no descriptions, the metrics depend on the stats.
+func (c *flattenCollector) Describe(ch chan<- *prometheus.Desc) {
+}
+
+// CollectStats returns the metrics of the collector for a stats snapshot. The values are visited in path order,
+// so of the paths that sanitize to the same name always the same one is exported.
+func (c *flattenCollector) CollectStats(stats Stats, ch chan<- prometheus.Metric) {
+	names := make(map[string]bool)
+
+	walkLeaves(map[string]interface{}(stats), nil, func(segments []string, value interface{}) {
+		v, ok := value.(float64)
+		if !ok || !c.flattening.exports(segments) || c.isMapped(segments) {
+			return
+		}
+
+		name := prometheus.BuildFQName(c.namespace, "", invalidMetricNameRE.ReplaceAllString(strings.Join(segments, "_"), "_"))
+		if c.mappedNames[name] || names[name] {
+			return
+		}
+		names[name] = true
+
+		desc := prometheus.NewDesc(name, strings.Join(segments, "."), nil, c.constLabels)
+		metric, err := prometheus.NewConstMetric(desc, prometheus.UntypedValue, v)
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(desc, err)
+			return
+		}
+		ch <- metric
+	})
+}
+
+// isMapped reports whether a mapping exports the value at the path
+func (c *flattenCollector) isMapped(segments []string) bool {
+	for _, mapping := range c.mapped {
+		if mapping.matches(segments) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,83 @@
+package collector
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+)
+
+// LabelTemplates render the collector label and extra labels of a target from the BeatInfo of the
+// detected beat, e.g. {{.Beat}}-{{.Name}}
+type LabelTemplates struct {
+	Collector *template.Template
+	Labels    map[string]*template.Template
+}
+
+// NewLabelTemplates parses the collector label template and the extra label templates, both are optional.
+// The templates are tried on an empty BeatInfo so unknown fields are reported here rather than on detection.
+func NewLabelTemplates(collectorTemplate string, labelTemplates map[string]string) (LabelTemplates, error) {
+	templates := LabelTemplates{}
+
+	if collectorTemplate != "" {
+		tmpl, err := template.New("collector").Parse(collectorTemplate)
+		if err != nil {
+			return LabelTemplates{}, err
+		}
+		templates.Collector = tmpl
+	}
+
+	for name, text := range labelTemplates {
+		tmpl, err := template.New(name).Parse(text)
+		if err != nil {
+			return LabelTemplates{}, err
+		}
+
+		if templates.Labels == nil {
+			templates.Labels = make(map[string]*template.Template, len(labelTemplates))
+		}
+		templates.Labels[name] = tmpl
+	}
+
+	_, _, err := templates.render(&BeatInfo{})
+	if err != nil {
+		return LabelTemplates{}, err
+	}
+
+	return templates, nil
+}
+
+// render executes the templates on beatInfo, labels rendered empty are left out
+func (t LabelTemplates) render(beatInfo *BeatInfo) (string, map[string]string, error) {
+	collectorLabel := ""
+	if t.Collector != nil {
+		var err error
+		collectorLabel, err = execute(t.Collector, beatInfo)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	labels := make(map[string]string, len(t.Labels))
+	for name, tmpl := range t.Labels {
+		value, err := execute(tmpl, beatInfo)
+		if err != nil {
+			return "", nil, err
+		}
+
+		if value != "" {
+			labels[name] = value
+		}
+	}
+
+	return collectorLabel, labels, nil
+}
+
+func execute(tmpl *template.Template, beatInfo *BeatInfo) (string, error) {
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, beatInfo)
+	if err != nil {
+		return "", fmt.Errorf("label template %s: %v", tmpl.Name(), err)
+	}
+
+	return buf.String(), nil
+}
@@ -1,138 +1,539 @@
 package collector
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 )
 
+const (
+	// detectMinInterval and detectMaxInterval bound the backoff between beat type detection attempts
+	detectMinInterval = 1 * time.Second
+	detectMaxInterval = 30 * time.Second
+
+	// rootCheckInterval is the interval of the beat info comparisons of beats without an ephemeral id
+	rootCheckInterval = 1 * time.Minute
+)
+
+// TargetCollector collects the metrics of a single beat target
+type TargetCollector interface {
+	prometheus.Collector
+	CollectContext(ctx context.Context, ch chan<- prometheus.Metric)
+	GetCollectorInfo() BeatInfo
+	GetCollectorLabel() string
+	GetScrapeStatus() ScrapeStatus
+	GetSchemaReport() SchemaReport
+	Detected() <-chan struct{}
+	Stop()
+}
+
+var (
+	errNotDetected = errors.New("beat type not detected yet")
+	errNoBeatType  = errors.New("beat info has no beat type")
+	errNotPolled   = errors.New("target not polled yet")
+	errStale       = errors.New("last snapshot is older than max age")
+)
+
 type mainCollector struct {
-	Collectors map[string]prometheus.Collector
-	Stats      *Stats
-	client     *http.Client
-	beatURL    *url.URL
-	name       string
-	targetDesc *prometheus.Desc
-	targetUp   *prometheus.Desc
-	metrics    exportedMetrics
+	Collectors     []StatsCollector
+	client         *http.Client
+	beatURL        *url.URL
+	name           string
+	targetDesc     *prometheus.Desc
+	targetInfo     *prometheus.Desc
+	targetUp       *prometheus.Desc
+	snapshotAge    *prometheus.Desc
+	telemetryDescs telemetryDescs
+	mappings       *Mappings
 	CollectorLabel string
-	beatInfo   *BeatInfo
+	constLabels    prometheus.Labels
+	defaultLabel   string
+	staticLabels   map[string]string
+	templates      LabelTemplates
+	beatInfo       *BeatInfo
+	detected       bool
+	detectedCh     chan struct{}
+	detectErr      error
+	ephemeralID    string
+	rootChecked    time.Time
+	mtx            sync.RWMutex
+	stopCh         chan bool
+	scrapeStatus   ScrapeStatus
+	telemetry      scrapeTelemetry
+	schemaReport   SchemaReport
+	statusMtx      sync.Mutex
+	polling        Polling
+	snapshot       statsSnapshot
+	snapshotMtx    sync.Mutex
+}
+
+// ScrapeStatus outcome of the last collection of a target
+type ScrapeStatus struct {
+	Time     time.Time `json:"time"`
+	Duration float64   `json:"duration_seconds"`
+	Success  bool      `json:"success"`
+	Error    string    `json:"error,omitempty"`
 }
 
-// NewMainCollector constructor
-func NewMainCollector(client *http.Client, url *url.URL, name string, collectorLabel string) (string, BeatInfo, prometheus.Collector) {
-	if collectorLabel == "" {
+// Polling makes the collector scrape the beat every Interval in the background and serve the last snapshot,
+// snapshots older than MaxAge are not exported. The zero value scrapes the beat on every collection.
+type Polling struct {
+	Interval time.Duration
+	MaxAge   time.Duration
+}
+
+// statsSnapshot result of the last background scrape
+type statsSnapshot struct {
+	time  time.Time
+	stats Stats
+	err   error
+}
+
+// NewMainCollector constructor, the beat type is detected in the background until the target answers.
+// labels are added to every metric of the target next to the collector label. Without a collector label
+// the collector template of templates renders it once the beat is detected, until then it is host:port.
+// The metrics of the beat are exported as defined by mappings.
+func NewMainCollector(client *http.Client, url *url.URL, name string, collectorLabel string, labels map[string]string, templates LabelTemplates, polling Polling, mappings *Mappings) (string, TargetCollector) {
+	if collectorLabel != "" {
+		templates.Collector = nil
+	} else {
 		collectorLabel = fmt.Sprintf("%s:%s", url.Hostname(), url.Port())
 	}
+
 	beat := &mainCollector{
-		Collectors: make(map[string]prometheus.Collector),
-		Stats:      &Stats{},
-		client:     client,
-		beatURL:    url,
-		name:       name,
-		CollectorLabel: collectorLabel,
-		metrics:    exportedMetrics{},
-		beatInfo: &BeatInfo{},
+		client:       client,
+		beatURL:      url,
+		name:         name,
+		defaultLabel: collectorLabel,
+		staticLabels: labels,
+		templates:    templates,
+		polling:      polling,
+		mappings:     mappings,
+		beatInfo:     &BeatInfo{},
+		detectedCh:   make(chan struct{}),
+		stopCh:       make(chan bool),
 	}
 
-	err := beat.loadBeatType(client, url)
-	if err != nil {
+	// target_info with only the initial collector label is the one descriptor that is the same for every target
+	// whatever its beat type or labels, it is what identifies the collector in the registry
+	beat.targetDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(name, "target", "info"),
+		"target information",
+		[]string{"version", "beat"},
+		prometheus.Labels{"collector": collectorLabel})
+
+	beat.setLabels()
+	beat.buildCollectors()
+
+	go beat.detectBeatType()
+
+	if polling.Interval > 0 {
+		go beat.poll()
+	}
+
+	return collectorLabel, beat
+}
+
+// detectBeatType retries loadBeatType with a backoff until it succeeds or the collector is stopped
+func (b *mainCollector) detectBeatType() {
+	interval := detectMinInterval
+
+	var beatInfo *BeatInfo
+
+	// Stop cancels a request in flight, not only the wait for the next attempt
+	ctx, cancel := b.stopContext()
+	defer cancel()
+
+	for {
+		var err error
+		beatInfo, err = b.loadBeatType(ctx, b.client, b.beatURL)
+		if err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		// the requests of the detection are the only ones to a beat that is down from the start
+		b.recordRootFailure(err)
+
+		b.mtx.Lock()
+		b.detectErr = err
+		b.mtx.Unlock()
+
 		log.WithFields(log.Fields{
 			"err": err,
-		}).Errorf("Failed to load beat type (%s): %v", beat.CollectorLabel, err)
+		}).Errorf("Failed to load beat type (%s): %v, retrying in %s", b.CollectorLabel, err, interval)
+
+		select {
+		case <-time.After(interval):
+		case <-b.stopCh:
+			return
+		}
+
+		interval *= 2
+		if interval > detectMaxInterval {
+			interval = detectMaxInterval
+		}
 	}
 
-	beat.targetDesc = prometheus.NewDesc(
-		prometheus.BuildFQName(name, "target", "info"),
+	b.mtx.Lock()
+	*b.beatInfo = *beatInfo
+	b.detected = true
+	b.rootChecked = time.Now()
+	b.setLabels()
+	b.buildCollectors()
+	collectorLabel := b.CollectorLabel
+	b.mtx.Unlock()
+	close(b.detectedCh)
+
+	log.WithFields(
+		log.Fields{
+			"beat":     beatInfo.Beat,
+			"version":  beatInfo.Version,
+			"name":     beatInfo.Name,
+			"hostname": beatInfo.Hostname,
+			"uuid":     beatInfo.UUID,
+		}).Infof("%s: Target beat configuration loaded successfully!", collectorLabel)
+}
+
+// setLabels sets the collector label and const labels, rendering the label templates once the beat is detected
+func (b *mainCollector) setLabels() {
+	collectorLabel := b.defaultLabel
+	constLabels := prometheus.Labels{}
+
+	if b.detected {
+		renderedLabel, templateLabels, err := b.templates.render(b.beatInfo)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"err": err,
+			}).Errorf("Failed to render label templates (%s): %v", b.defaultLabel, err)
+		}
+
+		if renderedLabel != "" {
+			collectorLabel = renderedLabel
+		}
+		for labelName, value := range templateLabels {
+			constLabels[labelName] = value
+		}
+	}
+
+	for labelName, value := range b.staticLabels {
+		constLabels[labelName] = value
+	}
+	constLabels["collector"] = collectorLabel
+
+	b.CollectorLabel = collectorLabel
+	b.constLabels = constLabels
+}
+
+// buildCollectors creates the descriptors and the sub-collectors of the mapping sets of the detected beat type
+func (b *mainCollector) buildCollectors() {
+	b.targetInfo = prometheus.NewDesc(
+		prometheus.BuildFQName(b.name, "target", "info"),
 		"target information",
-		nil,
-		prometheus.Labels{"version": beat.beatInfo.Version, "beat": beat.beatInfo.Beat, "collector": beat.CollectorLabel})
+		[]string{"version", "beat"},
+		b.constLabels)
+
+	namespace, namespaceLabels := b.mappings.namespace(b.beatInfo.Beat, b.constLabels)
+	// without a beat type or prefix the metric would be a bare up, beat_exporter_target_up covers that case
+	b.targetUp = nil
+	if namespace != "" {
+		b.targetUp = prometheus.NewDesc(
+			prometheus.BuildFQName("", namespace, "up"),
+			"Target up",
+			nil,
+			namespaceLabels)
+	}
 
-	beat.targetUp = prometheus.NewDesc(
-		prometheus.BuildFQName("", beat.beatInfo.Beat, "up"),
-		"Target up",
+	b.snapshotAge = prometheus.NewDesc(
+		prometheus.BuildFQName(b.name, "target", "snapshot_age_seconds"),
+		"Age of the stats snapshot of a polled target",
 		nil,
-		prometheus.Labels{"collector": beat.CollectorLabel})
+		b.constLabels)
+
+	b.telemetryDescs = newTelemetryDescs(b.name, b.constLabels)
+
+	b.Collectors = b.mappings.collectors(b.beatInfo, b.constLabels)
+}
 
-	beat.Collectors["beat"] = NewBeatCollector(beat.beatInfo, beat.Stats, beat.CollectorLabel)
-	beat.Collectors["libbeat"] = NewLibBeatCollector(beat.beatInfo, beat.Stats, beat.CollectorLabel)
-	beat.Collectors["registrar"] = NewRegistrarCollector(beat.beatInfo, beat.Stats, beat.CollectorLabel)
-	beat.Collectors["filebeat"] = NewFilebeatCollector(beat.beatInfo, beat.Stats, beat.CollectorLabel)
-	beat.Collectors["metricbeat"] = NewMetricbeatCollector(beat.beatInfo, beat.Stats, beat.CollectorLabel)
-	beat.Collectors["auditd"] = NewAuditdCollector(beat.beatInfo, beat.Stats, beat.CollectorLabel)
+// Detected is closed once the beat type has been detected
+func (b *mainCollector) Detected() <-chan struct{} {
+	return b.detectedCh
+}
+
+// Stop ends the background beat type detection
+func (b *mainCollector) Stop() {
+	close(b.stopCh)
+}
 
-	return collectorLabel, beat.GetCollectorInfo(), beat
+// stopContext returns a context that is cancelled when the collector is stopped or cancel is called
+func (b *mainCollector) stopContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-b.stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, cancel
 }
 
-// Describe returns all descriptions of the collector.
+// Describe returns the descriptions that stay the same for the life of the collector. Metrics
+// of the sub-collectors are named after the beat type detected later on and are not described.
 func (b *mainCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- b.targetDesc
-	ch <- b.targetUp
+}
 
-	for _, metric := range b.metrics {
-		ch <- metric.desc
+// Collect returns the current state of all metrics of the collector.
+func (b *mainCollector) Collect(ch chan<- prometheus.Metric) {
+	b.CollectContext(context.Background(), ch)
+}
+
+// CollectContext returns the current state of all metrics of the collector, ctx bounds the request to the beat.
+// Polled targets return their last snapshot.
+func (b *mainCollector) CollectContext(ctx context.Context, ch chan<- prometheus.Metric) {
+	var (
+		stats Stats
+		err   error
+		age   time.Duration
+	)
+
+	if b.polling.Interval > 0 {
+		stats, age, err = b.lastSnapshot()
+	} else {
+		start := time.Now()
+		var size int
+		stats, size, err = b.scrape(ctx)
+		b.setScrapeStatus(start, size, err)
 	}
 
-	// standard collectors for all types of beats
-	b.Collectors["beat"].Describe(ch)
-	b.Collectors["libbeat"].Describe(ch)
-	b.Collectors["auditd"].Describe(ch)
+	b.statusMtx.Lock()
+	telemetry := b.telemetry.copy()
+	schemaReport := b.schemaReport
+	b.statusMtx.Unlock()
 
-	// Customized collectors per beat type
-	switch b.beatInfo.Beat {
-	case "filebeat":
-		b.Collectors["filebeat"].Describe(ch)
-		b.Collectors["registrar"].Describe(ch)
-	case "metricbeat":
-		b.Collectors["metricbeat"].Describe(ch)
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+
+	telemetry.collect(b.telemetryDescs, ch)
+	schemaReport.collect(b.telemetryDescs, ch)
+
+	if b.polling.Interval > 0 && err != errNotDetected && err != errNotPolled {
+		ch <- prometheus.MustNewConstMetric(b.snapshotAge, prometheus.GaugeValue, age.Seconds())
 	}
 
-}
+	stateErr := err
+	if err == errNotDetected {
+		stateErr = b.detectErr
+		if stateErr == nil {
+			stateErr = errNoBeatType // the first detection attempt is still running
+		}
+	}
+	collectState(b.telemetryDescs, b.beatInfo.Beat, targetState(stateErr), ch)
 
-// Collect returns the current state of all metrics of the collector.
-func (b *mainCollector) Collect(ch chan<- prometheus.Metric) {
+	if err == errNotDetected || err == errNotPolled || err == errStale {
+		b.collectUp(0, ch) // no stats to export
+		return
+	}
 
-	err := b.fetchStatsEndpoint()
 	if err != nil {
-		ch <- prometheus.MustNewConstMetric(b.targetUp, prometheus.GaugeValue, float64(0)) // set target down
-		log.Errorf("Failed getting /stats endpoint of target: " + err.Error())
+		b.collectUp(0, ch) // set target down
+		if b.polling.Interval == 0 {
+			log.Errorf("Failed getting /stats endpoint of target: " + err.Error())
+		}
 		return
 	}
 
-	ch <- prometheus.MustNewConstMetric(b.targetDesc, prometheus.GaugeValue, float64(1))
-	ch <- prometheus.MustNewConstMetric(b.targetUp, prometheus.GaugeValue, float64(1)) // target up
+	ch <- prometheus.MustNewConstMetric(b.targetInfo, prometheus.GaugeValue, float64(1), b.beatInfo.Version, b.beatInfo.Beat)
+	b.collectUp(1, ch) // target up
 
-	for _, i := range b.metrics {
-		ch <- prometheus.MustNewConstMetric(i.desc, i.valType, i.eval(b.Stats))
+	for _, statsCollector := range b.Collectors {
+		statsCollector.CollectStats(stats, ch)
 	}
+}
 
-	// standard collectors for all types of beats
-	b.Collectors["beat"].Collect(ch)
-	b.Collectors["libbeat"].Collect(ch)
-	b.Collectors["auditd"].Collect(ch)
+// collectUp sends the <beat>_up metric, unless the beat type is unknown without a metric prefix
+func (b *mainCollector) collectUp(value float64, ch chan<- prometheus.Metric) {
+	if b.targetUp != nil {
+		ch <- prometheus.MustNewConstMetric(b.targetUp, prometheus.GaugeValue, value)
+	}
+}
 
-	// Customized collectors per beat type
-	switch b.beatInfo.Beat {
-	case "filebeat":
-		b.Collectors["filebeat"].Collect(ch)
-		b.Collectors["registrar"].Collect(ch)
-	case "metricbeat":
-		b.Collectors["metricbeat"].Collect(ch)
+// poll scrapes the beat every poll interval once it is detected and keeps the result as the last snapshot
+func (b *mainCollector) poll() {
+	select {
+	case <-b.detectedCh:
+	case <-b.stopCh:
+		return
 	}
 
+	ticker := time.NewTicker(b.polling.Interval)
+	defer ticker.Stop()
+
+	for {
+		start := time.Now()
+		stats, size, err := b.scrape(context.Background())
+		b.setScrapeStatus(start, size, err)
+		if err != nil {
+			log.Errorf("Failed getting /stats endpoint of target: " + err.Error())
+		}
+
+		b.snapshotMtx.Lock()
+		b.snapshot = statsSnapshot{time: start, stats: stats, err: err}
+		b.snapshotMtx.Unlock()
+
+		select {
+		case <-ticker.C:
+		case <-b.stopCh:
+			return
+		}
+	}
 }
 
-func (b *mainCollector) fetchStatsEndpoint() error {
+// lastSnapshot returns the stats of the last poll with its age, or errStale when it is older than the max age
+func (b *mainCollector) lastSnapshot() (Stats, time.Duration, error) {
+	b.snapshotMtx.Lock()
+	snapshot := b.snapshot
+	b.snapshotMtx.Unlock()
+
+	if snapshot.time.IsZero() {
+		b.mtx.RLock()
+		detected := b.detected
+		b.mtx.RUnlock()
+
+		if !detected {
+			return nil, 0, errNotDetected
+		}
+		return nil, 0, errNotPolled
+	}
+
+	age := time.Since(snapshot.time)
+	if b.polling.MaxAge > 0 && age > b.polling.MaxAge {
+		return nil, age, errStale
+	}
+
+	return snapshot.stats, age, snapshot.err
+}
+
+// scrape fetches a stats snapshot, detects the beat again when it was restarted or replaced and checks the
+// snapshot against the mappings of the beat
+func (b *mainCollector) scrape(ctx context.Context) (Stats, int, error) {
+	b.mtx.RLock()
+	detected, lastEphemeralID := b.detected, b.ephemeralID
+	b.mtx.RUnlock()
+
+	if !detected {
+		return nil, 0, errNotDetected
+	}
 
-	response, err := b.client.Get(b.beatURL.String() + "/stats")
+	stats, size, err := b.fetchStatsEndpoint(ctx)
+	if err != nil {
+		return nil, size, err
+	}
+
+	// a new ephemeral id means the beat process restarted, possibly upgraded or replaced by another beat.
+	// Older beats do not report one, their beat info is compared with the root endpoint from time to time.
+	ephemeralID := stats.ephemeralID()
+	switch {
+	case ephemeralID == "" && lastEphemeralID == "":
+		if b.rootCheckDue() {
+			b.redetectBeatType(ctx, "")
+		}
+	case ephemeralID == lastEphemeralID:
+	case lastEphemeralID == "":
+		b.mtx.Lock()
+		b.ephemeralID = ephemeralID
+		b.mtx.Unlock()
+	default:
+		b.redetectBeatType(ctx, ephemeralID)
+	}
+
+	b.mtx.RLock()
+	schemaReport := b.mappings.checkSchema(b.beatInfo.Beat, stats, time.Now())
+	b.mtx.RUnlock()
+
+	b.statusMtx.Lock()
+	b.schemaReport = schemaReport
+	b.statusMtx.Unlock()
+
+	return stats, size, nil
+}
+
+// rootCheckDue reports whether the beat info of a beat without ephemeral id is to be compared again, every
+// rootCheckInterval
+func (b *mainCollector) rootCheckDue() bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	now := time.Now()
+	if now.Sub(b.rootChecked) < rootCheckInterval {
+		return false
+	}
+	b.rootChecked = now
+
+	return true
+}
+
+// redetectBeatType reloads the beat info and rebuilds the descriptors and sub-collectors when it changed.
+// The ephemeral id is only stored on success, so a failed attempt is retried on the next scrape.
+func (b *mainCollector) redetectBeatType(ctx context.Context, ephemeralID string) {
+	beatInfo, err := b.loadBeatType(ctx, b.client, b.beatURL)
+	if err != nil {
+		b.recordRootFailure(err)
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Errorf("Failed to reload beat type after restart (%s): %v", b.GetCollectorLabel(), err)
+		return
+	}
+
+	b.mtx.Lock()
+	changed := *beatInfo != *b.beatInfo
+	*b.beatInfo = *beatInfo
+	b.ephemeralID = ephemeralID
+	if changed {
+		b.setLabels()
+		b.buildCollectors()
+	}
+	collectorLabel := b.CollectorLabel
+	b.mtx.Unlock()
+
+	if changed {
+		log.WithFields(
+			log.Fields{
+				"beat":     beatInfo.Beat,
+				"version":  beatInfo.Version,
+				"name":     beatInfo.Name,
+				"hostname": beatInfo.Hostname,
+				"uuid":     beatInfo.UUID,
+			}).Infof("%s: Target beat changed, configuration reloaded", collectorLabel)
+	}
+}
+
+// fetchStatsEndpoint decodes the stats into a new snapshot, so concurrent scrapes do not share values.
+// It also returns the size of the response, errors are scrapeErrors with the reason of the failure.
+func (b *mainCollector) fetchStatsEndpoint(ctx context.Context) (Stats, int, error) {
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, b.beatURL.String()+"/stats", nil)
+	if err != nil {
+		return nil, 0, newScrapeError(failureDial, err)
+	}
+
+	response, err := b.client.Do(request)
 	if err != nil {
 		log.Errorf("Could not fetch stats endpoint of target: %v", b.beatURL.String())
-		return err
+		return nil, 0, newScrapeError(failureDial, err)
 	}
 
 	defer response.Body.Close()
@@ -140,46 +541,117 @@ func (b *mainCollector) fetchStatsEndpoint() error {
 	bodyBytes, err := ioutil.ReadAll(response.Body)
 	if err != nil {
 		log.Error("Can't read body of response")
-		return err
+		return nil, len(bodyBytes), newScrapeError(failureRead, err)
 	}
 
-	err = json.Unmarshal(bodyBytes, &b.Stats)
+	if response.StatusCode != http.StatusOK {
+		log.Errorf("Stats URL: %q status code: %d", b.beatURL.String()+"/stats", response.StatusCode)
+		return nil, len(bodyBytes), newScrapeError(failureStatus, fmt.Errorf("unexpected status code %d", response.StatusCode))
+	}
+
+	stats := Stats{}
+	err = json.Unmarshal(bodyBytes, &stats)
 	if err != nil {
 		log.Error("Could not parse JSON response for target")
-		return err
+		return nil, len(bodyBytes), newScrapeError(failureDecode, err)
 	}
 
-	return nil
+	return stats, len(bodyBytes), nil
 }
 
-func (b *mainCollector) loadBeatType(client *http.Client, url *url.URL) error {
-	response, err := client.Get(url.String())
+// loadBeatType fetches the beat info, errors are scrapeErrors with the reason of the failure or errNoBeatType
+func (b *mainCollector) loadBeatType(ctx context.Context, client *http.Client, url *url.URL) (*BeatInfo, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url.String(), nil)
+	if err != nil {
+		return nil, newScrapeError(failureDial, err)
+	}
+
+	response, err := client.Do(request)
 	if err != nil {
-		return err
+		return nil, newScrapeError(failureDial, err)
 	}
 	defer response.Body.Close()
 
 	if response.StatusCode != http.StatusOK {
 		log.Errorf("Beat URL: %q status code: %d", url.String(), response.StatusCode)
-		return err
+		return nil, newScrapeError(failureStatus, fmt.Errorf("unexpected status code %d", response.StatusCode))
 	}
 
 	bodyBytes, err := ioutil.ReadAll(response.Body)
 	if err != nil {
 		log.Error("Can't read body of response")
-		return err
+		return nil, newScrapeError(failureRead, err)
 	}
 
-	err = json.Unmarshal(bodyBytes, &b.beatInfo)
+	beatInfo := &BeatInfo{}
+	err = json.Unmarshal(bodyBytes, beatInfo)
 	if err != nil {
 		log.Error("Could not parse JSON response for target")
-		return err
+		return nil, newScrapeError(failureDecode, err)
+	}
+
+	if beatInfo.Beat == "" {
+		return nil, errNoBeatType
+	}
+
+	return beatInfo, nil
+}
+
+// setScrapeStatus records the outcome of a scrape, attempts before the beat is detected are not counted in the telemetry
+func (b *mainCollector) setScrapeStatus(start time.Time, size int, err error) {
+	end := time.Now()
+	status := ScrapeStatus{
+		Time:     start,
+		Duration: end.Sub(start).Seconds(),
+		Success:  err == nil,
 	}
+	if err != nil {
+		status.Error = err.Error()
+	}
+
+	b.statusMtx.Lock()
+	b.scrapeStatus = status
+	if err != errNotDetected {
+		b.telemetry.record(end, status.Duration, size, err)
+	}
+	b.statusMtx.Unlock()
+}
+
+// recordRootFailure counts a failed request to the root endpoint, the other telemetry covers the stats endpoint only
+func (b *mainCollector) recordRootFailure(err error) {
+	b.statusMtx.Lock()
+	b.telemetry.recordFailure(err)
+	b.statusMtx.Unlock()
+}
+
+// GetScrapeStatus returns the outcome of the last collection
+func (b *mainCollector) GetScrapeStatus() ScrapeStatus {
+	b.statusMtx.Lock()
+	defer b.statusMtx.Unlock()
 
-	return nil
+	return b.scrapeStatus
+}
+
+// GetSchemaReport returns the schema report of the last stats, its Time is zero before the first successful scrape
+func (b *mainCollector) GetSchemaReport() SchemaReport {
+	b.statusMtx.Lock()
+	defer b.statusMtx.Unlock()
+
+	return b.schemaReport
+}
+
+// GetCollectorLabel returns the current collector label, it changes when a label template is rendered
+func (b *mainCollector) GetCollectorLabel() string {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+
+	return b.CollectorLabel
 }
 
 func (b *mainCollector) GetCollectorInfo() BeatInfo {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+
 	bi := BeatInfo{b.beatInfo.Beat, b.beatInfo.Hostname, b.beatInfo.Name, b.beatInfo.UUID, b.beatInfo.Version}
-    return bi
-}
\ No newline at end of file
+	return bi
+}
@@ -0,0 +1,529 @@
+package collector
+
+import (
+	"embed"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v2"
+)
+
+// builtinMappings the mappings exported by default, one directory per metric schema and one file per set
+//
+//go:embed mappings
+var builtinMappings embed.FS
+
+var (
+	metricNameRE = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNameRE  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// MetricMapping maps the stats value at Path, a dot separated list of keys, to a metric named <beat>_<Name>.
+// A {label} segment matches every key at its level and sets it as the value of label. Without such segments,
+// a value missing from the stats is exported as 0.
+type MetricMapping struct {
+	Name   string            `yaml:"name"`
+	Help   string            `yaml:"help"`
+	Type   string            `yaml:"type"`
+	Path   string            `yaml:"path"`
+	Labels map[string]string `yaml:"labels"`
+	// ValueLabel exports a string value as this label of a metric with value 1
+	ValueLabel string  `yaml:"value_label"`
+	DivideBy   float64 `yaml:"divide_by"`
+	// When and Unless export the metric only when all, respectively none, of the paths have the given value
+	When   map[string]string `yaml:"when"`
+	Unless map[string]string `yaml:"unless"`
+
+	segments   []string
+	wildcards  []string
+	valueType  prometheus.ValueType
+	conditions map[string][]string
+}
+
+// MappingSet mappings of one file, they apply to the beat types in Beats or to every beat when it is empty
+type MappingSet struct {
+	Name    string          `yaml:"-"`
+	Beats   []string        `yaml:"beats"`
+	Metrics []MetricMapping `yaml:"metrics"`
+}
+
+// Mappings sets of metric mappings, sorted by name
+type Mappings struct {
+	sets       []*MappingSet
+	flattening *Flattening
+	prefix     string
+}
+
+// LoadMappings loads the built-in mappings of the metric schema, v1 or v2, and the *.yml files of dir, if set.
+// A file of dir replaces the built-in set with the same name, e.g. libbeat.yml, an empty one disables it.
+func LoadMappings(schema string, dir string) (*Mappings, error) {
+	sets := make(map[string]*MappingSet)
+
+	builtins, err := builtinMappings.ReadDir(path.Join("mappings", schema))
+	if err != nil || schema == "" || strings.ContainsAny(schema, "./") {
+		return nil, fmt.Errorf("unknown metric schema %q, expected one of %s", schema, strings.Join(Schemas(), ", "))
+	}
+	for _, entry := range builtins {
+		content, err := builtinMappings.ReadFile(path.Join("mappings", schema, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		set, err := parseMappingSet(strings.TrimSuffix(entry.Name(), ".yml"), content)
+		if err != nil {
+			return nil, err
+		}
+		sets[set.Name] = set
+	}
+
+	if dir != "" {
+		files, err := filepath.Glob(filepath.Join(dir, "*.yml"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			content, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+
+			set, err := parseMappingSet(strings.TrimSuffix(filepath.Base(file), ".yml"), content)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", file, err)
+			}
+			sets[set.Name] = set
+		}
+	}
+
+	mappings := &Mappings{}
+	for _, set := range sets {
+		mappings.sets = append(mappings.sets, set)
+	}
+	sort.Slice(mappings.sets, func(i, j int) bool {
+		return mappings.sets[i].Name < mappings.sets[j].Name
+	})
+
+	err = mappings.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return mappings, nil
+}
+
+// Schemas returns the metric schemas of the built-in mappings
+func Schemas() []string {
+	var schemas []string
+
+	entries, _ := builtinMappings.ReadDir("mappings")
+	for _, entry := range entries {
+		if entry.IsDir() {
+			schemas = append(schemas, entry.Name())
+		}
+	}
+
+	return schemas
+}
+
+func parseMappingSet(name string, content []byte) (*MappingSet, error) {
+	set := &MappingSet{}
+	err := yaml.UnmarshalStrict(content, set)
+	if err != nil {
+		return nil, err
+	}
+	set.Name = name
+
+	for i := range set.Metrics {
+		err = set.Metrics[i].compile()
+		if err != nil {
+			return nil, fmt.Errorf("metrics[%d]: %v", i, err)
+		}
+	}
+
+	return set, nil
+}
+
+// validate checks that the mappings of a metric name agree on its type and help across all sets
+func (m *Mappings) validate() error {
+	first := make(map[string]*MetricMapping)
+	for _, set := range m.sets {
+		for i := range set.Metrics {
+			mapping := &set.Metrics[i]
+			other, ok := first[mapping.Name]
+			if !ok {
+				first[mapping.Name] = mapping
+				continue
+			}
+
+			if mapping.valueType != other.valueType || mapping.Help != other.Help {
+				return fmt.Errorf("mapping %s: metric %s has a different type or help than in another mapping", set.Name, mapping.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// compile validates the mapping and splits its paths
+func (m *MetricMapping) compile() error {
+	if !metricNameRE.MatchString(m.Name) {
+		return fmt.Errorf("invalid metric name %q", m.Name)
+	}
+
+	switch m.Type {
+	case "counter":
+		m.valueType = prometheus.CounterValue
+	case "gauge":
+		m.valueType = prometheus.GaugeValue
+	case "untyped", "":
+		m.valueType = prometheus.UntypedValue
+	default:
+		return fmt.Errorf("%s: invalid type %q, expected counter, gauge or untyped", m.Name, m.Type)
+	}
+
+	if m.Help == "" {
+		m.Help = m.Path
+	}
+
+	if m.DivideBy < 0 {
+		return fmt.Errorf("%s: divide_by must not be negative", m.Name)
+	}
+
+	segments, err := splitPath(m.Path)
+	if err != nil {
+		return fmt.Errorf("%s: %v", m.Name, err)
+	}
+	m.segments = segments
+
+	labelNames := make(map[string]bool)
+	addLabel := func(name string) error {
+		if !labelNameRE.MatchString(name) || strings.HasPrefix(name, "__") {
+			return fmt.Errorf("%s: invalid label name %q", m.Name, name)
+		}
+		if name == "collector" || name == "beat" {
+			return fmt.Errorf("%s: label %q is reserved for the target", m.Name, name)
+		}
+		if labelNames[name] {
+			return fmt.Errorf("%s: label %q is set twice", m.Name, name)
+		}
+		labelNames[name] = true
+		return nil
+	}
+
+	m.wildcards = nil
+	for _, segment := range segments {
+		if name, ok := wildcardLabel(segment); ok {
+			err = addLabel(name)
+			if err != nil {
+				return err
+			}
+			m.wildcards = append(m.wildcards, name)
+		}
+	}
+	if m.ValueLabel != "" {
+		err = addLabel(m.ValueLabel)
+		if err != nil {
+			return err
+		}
+	}
+	for name := range m.Labels {
+		err = addLabel(name)
+		if err != nil {
+			return err
+		}
+	}
+
+	m.conditions = make(map[string][]string, len(m.When)+len(m.Unless))
+	for _, conditions := range []map[string]string{m.When, m.Unless} {
+		for conditionPath := range conditions {
+			segments, err := splitPath(conditionPath)
+			if err != nil {
+				return fmt.Errorf("%s: %v", m.Name, err)
+			}
+			for _, segment := range segments {
+				if _, ok := wildcardLabel(segment); ok {
+					return fmt.Errorf("%s: condition path %q has a wildcard", m.Name, conditionPath)
+				}
+			}
+			m.conditions[conditionPath] = segments
+		}
+	}
+
+	return nil
+}
+
+func splitPath(statsPath string) ([]string, error) {
+	segments := strings.Split(statsPath, ".")
+	for _, segment := range segments {
+		if segment == "" {
+			return nil, fmt.Errorf("invalid path %q", statsPath)
+		}
+	}
+
+	return segments, nil
+}
+
+// applies reports whether the stats meet the when and unless conditions of the mapping
+func (m *MetricMapping) applies(stats Stats) bool {
+	for conditionPath, expected := range m.When {
+		if stats.lookupString(m.conditions[conditionPath]) != expected {
+			return false
+		}
+	}
+	for conditionPath, expected := range m.Unless {
+		if stats.lookupString(m.conditions[conditionPath]) == expected {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matches reports whether the mapping exports the value at the path segments
+func (m *MetricMapping) matches(segments []string) bool {
+	if len(segments) != len(m.segments) {
+		return false
+	}
+
+	for i, segment := range m.segments {
+		if _, ok := wildcardLabel(segment); !ok && segment != segments[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// wildcardLabel returns the label name of a {label} path segment
+func wildcardLabel(segment string) (string, bool) {
+	if len(segment) < 2 || segment[0] != '{' || segment[len(segment)-1] != '}' {
+		return "", false
+	}
+
+	return segment[1 : len(segment)-1], true
+}
+
+// LabelNames returns the sorted names of the labels the mappings set on their metrics, next to the collector and
+// beat labels of the target
+func (m *Mappings) LabelNames() []string {
+	seen := make(map[string]bool)
+	var names []string
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	for _, set := range m.sets {
+		for _, mapping := range set.Metrics {
+			for _, name := range mapping.wildcards {
+				add(name)
+			}
+			add(mapping.ValueLabel)
+			for name := range mapping.Labels {
+				add(name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// SetPrefix names the metrics <prefix>_<name> instead of <beat>_<name> and sets the beat type as the beat label,
+// so the metrics shared by all beats have the same name whatever the beat type
+func (m *Mappings) SetPrefix(prefix string) error {
+	if !metricNameRE.MatchString(prefix) {
+		return fmt.Errorf("invalid metric prefix %q", prefix)
+	}
+
+	m.prefix = prefix
+	return nil
+}
+
+// namespace returns the prefix of the metric names of the beat type and the const labels of its metrics
+func (m *Mappings) namespace(beat string, constLabels prometheus.Labels) (string, prometheus.Labels) {
+	if m.prefix == "" {
+		return beat, constLabels
+	}
+
+	return m.prefix, withLabels(constLabels, prometheus.Labels{"beat": beat})
+}
+
+// mappingsOf returns the mappings of the sets that apply to the beat type
+func (m *Mappings) mappingsOf(beat string) []*MetricMapping {
+	var mappings []*MetricMapping
+	for _, set := range m.sets {
+		if !set.appliesTo(beat) {
+			continue
+		}
+
+		for i := range set.Metrics {
+			mappings = append(mappings, &set.Metrics[i])
+		}
+	}
+
+	return mappings
+}
+
+// collectors returns the collectors of the sets that apply to the beat type, followed by the flattening if enabled
+func (m *Mappings) collectors(beatInfo *BeatInfo, constLabels prometheus.Labels) []StatsCollector {
+	namespace, constLabels := m.namespace(beatInfo.Beat, constLabels)
+
+	var collectors []StatsCollector
+	for _, set := range m.sets {
+		if !set.appliesTo(beatInfo.Beat) {
+			continue
+		}
+
+		collector := &mappingCollector{}
+		for i := range set.Metrics {
+			mapping := &set.Metrics[i]
+			labelNames := mapping.wildcards
+			if mapping.ValueLabel != "" {
+				labelNames = append(labelNames[:len(labelNames):len(labelNames)], mapping.ValueLabel)
+			}
+
+			collector.metrics = append(collector.metrics, mappedMetric{
+				mapping: mapping,
+				desc: prometheus.NewDesc(
+					prometheus.BuildFQName(namespace, "", mapping.Name),
+					mapping.Help,
+					labelNames, withLabels(constLabels, mapping.Labels),
+				),
+			})
+		}
+		collectors = append(collectors, collector)
+	}
+
+	if m.flattening != nil {
+		collectors = append(collectors, newFlattenCollector(m.flattening, namespace, constLabels, m.mappingsOf(beatInfo.Beat)))
+	}
+
+	return collectors
+}
+
+func (s *MappingSet) appliesTo(beat string) bool {
+	if len(s.Beats) == 0 {
+		return true
+	}
+
+	for _, name := range s.Beats {
+		if name == beat {
+			return true
+		}
+	}
+
+	return false
+}
+
+// mappingCollector exports the metrics of a mapping set
+type mappingCollector struct {
+	metrics []mappedMetric
+}
+
+type mappedMetric struct {
+	mapping *MetricMapping
+	desc    *prometheus.Desc
+}
+
+// Describe returns all descriptions of the collector.
+func (c *mappingCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, metric := range c.metrics {
+		ch <- metric.desc
+	}
+}
+
+// CollectStats returns the metrics of the collector for a stats snapshot.
+func (c *mappingCollector) CollectStats(stats Stats, ch chan<- prometheus.Metric) {
+	for _, metric := range c.metrics {
+		metric.collect(stats, ch)
+	}
+}
+
+func (m mappedMetric) collect(stats Stats, ch chan<- prometheus.Metric) {
+	if !m.mapping.applies(stats) {
+		return
+	}
+
+	m.walk(map[string]interface{}(stats), 0, nil, ch)
+}
+
+// walk descends the stats along the path of the mapping, following every key of wildcard segments
+func (m mappedMetric) walk(node interface{}, depth int, labelValues []string, ch chan<- prometheus.Metric) {
+	if depth == len(m.mapping.segments) {
+		m.send(node, labelValues, ch)
+		return
+	}
+
+	object, _ := node.(map[string]interface{})
+	segment := m.mapping.segments[depth]
+
+	if _, ok := wildcardLabel(segment); ok {
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			m.walk(object[key], depth+1, append(labelValues[:len(labelValues):len(labelValues)], key), ch)
+		}
+		return
+	}
+
+	child, ok := object[segment]
+	if !ok {
+		if len(m.mapping.wildcards) == 0 {
+			m.send(nil, labelValues, ch)
+		}
+		return
+	}
+
+	m.walk(child, depth+1, labelValues, ch)
+}
+
+// send exports a leaf of the stats, values that are neither numbers nor booleans are skipped
+func (m mappedMetric) send(value interface{}, labelValues []string, ch chan<- prometheus.Metric) {
+	var metricValue float64
+
+	if m.mapping.ValueLabel != "" {
+		label, ok := value.(string)
+		if !ok && value != nil {
+			return
+		}
+		labelValues = append(labelValues[:len(labelValues):len(labelValues)], label)
+		metricValue = 1
+	} else {
+		switch v := value.(type) {
+		case nil:
+		case float64:
+			metricValue = v
+		case bool:
+			if v {
+				metricValue = 1
+			}
+		default:
+			return
+		}
+
+		if m.mapping.DivideBy != 0 {
+			metricValue /= m.mapping.DivideBy
+		}
+	}
+
+	metric, err := prometheus.NewConstMetric(m.desc, m.mapping.valueType, metricValue, labelValues...)
+	if err != nil {
+		// e.g. a label of the mapping that is also a label of the target
+		ch <- prometheus.NewInvalidMetric(m.desc, err)
+		return
+	}
+	ch <- metric
+}
@@ -0,0 +1,101 @@
+package collector
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// SchemaReport compares a stats snapshot with the mappings of the beat. Unmapped lists the numeric and boolean
+// values of the stats that no mapping covers, Missing the paths of the mappings that have no value in the stats.
+type SchemaReport struct {
+	Time     time.Time
+	Unmapped []string
+	Missing  []string
+}
+
+// checkSchema returns the schema report of a stats snapshot of the beat type
+func (m *Mappings) checkSchema(beat string, stats Stats, now time.Time) SchemaReport {
+	mappings := m.mappingsOf(beat)
+	report := SchemaReport{Time: now}
+
+	walkLeaves(map[string]interface{}(stats), nil, func(segments []string, value interface{}) {
+		switch value.(type) {
+		case float64, bool:
+		default:
+			return
+		}
+
+		// mappings whose conditions are not met still cover their path, e.g. the bytes of another output type
+		for _, mapping := range mappings {
+			if mapping.matches(segments) {
+				return
+			}
+		}
+		report.Unmapped = append(report.Unmapped, strings.Join(segments, "."))
+	})
+
+	for _, mapping := range mappings {
+		if mapping.applies(stats) && !hasValue(map[string]interface{}(stats), mapping.segments) {
+			report.Missing = append(report.Missing, mapping.Path)
+		}
+	}
+	sort.Strings(report.Missing)
+
+	return report
+}
+
+// walkLeaves calls visit with the path and value of every value of node that is not an object, in key order
+func walkLeaves(node interface{}, segments []string, visit func(segments []string, value interface{})) {
+	object, ok := node.(map[string]interface{})
+	if !ok {
+		visit(segments, node)
+		return
+	}
+
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		walkLeaves(object[key], append(segments[:len(segments):len(segments)], key), visit)
+	}
+}
+
+// hasValue reports whether node has a value at the path segments, wildcard segments match any key
+func hasValue(node interface{}, segments []string) bool {
+	if len(segments) == 0 {
+		return true
+	}
+
+	object, ok := node.(map[string]interface{})
+	if !ok {
+		return false
+	}
+
+	if _, ok := wildcardLabel(segments[0]); ok {
+		for _, child := range object {
+			if hasValue(child, segments[1:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	child, ok := object[segments[0]]
+	return ok && hasValue(child, segments[1:])
+}
+
+// collect sends the number of unmapped and missing paths of the report
+func (r SchemaReport) collect(descs telemetryDescs, ch chan<- prometheus.Metric) {
+	if r.Time.IsZero() {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(descs.unmappedPaths, prometheus.GaugeValue, float64(len(r.Unmapped)))
+	ch <- prometheus.MustNewConstMetric(descs.missingPaths, prometheus.GaugeValue, float64(len(r.Missing)))
+}
@@ -1,6 +1,8 @@
 package collector
 
 import (
+	"fmt"
+
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -13,18 +15,56 @@ type BeatInfo struct {
 	Version  string `json:"version"`
 }
 
-//Stats stats endpoint json structure
-type Stats struct {
-	Beat       BeatStats   `json:"beat"`
-	LibBeat    LibBeat     `json:"libbeat"`
-	Registrar  Registrar   `json:"registrar"`
-	Filebeat   Filebeat    `json:"filebeat"`
-	Metricbeat Metricbeat  `json:"metricbeat"`
-	Auditd     AuditdStats `json:"auditd"`
+//Stats stats endpoint json structure, decoded generically so the metric mappings can address any value
+type Stats map[string]interface{}
+
+// StatsCollector exports the metrics of a stats snapshot, each scrape decodes a new one
+type StatsCollector interface {
+	Describe(ch chan<- *prometheus.Desc)
+	CollectStats(stats Stats, ch chan<- prometheus.Metric)
+}
+
+// lookup returns the value at path, a list of keys
+func (s Stats) lookup(path []string) (interface{}, bool) {
+	var node interface{} = map[string]interface{}(s)
+	for _, key := range path {
+		object, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		node, ok = object[key]
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return node, true
+}
+
+// lookupString returns the value at path formatted as a string, or an empty string when it is missing
+func (s Stats) lookupString(path []string) string {
+	value, ok := s.lookup(path)
+	if !ok || value == nil {
+		return ""
+	}
+
+	return fmt.Sprint(value)
+}
+
+// ephemeralID returns the id of the beat process, which changes when it restarts
+func (s Stats) ephemeralID() string {
+	return s.lookupString([]string{"beat", "info", "ephemeral_id"})
 }
 
-type exportedMetrics []struct {
-	desc    *prometheus.Desc
-	eval    func(stats *Stats) float64
-	valType prometheus.ValueType
+// withLabels returns the const labels of a target merged with the labels of a single metric
+func withLabels(constLabels prometheus.Labels, labels prometheus.Labels) prometheus.Labels {
+	merged := make(prometheus.Labels, len(constLabels)+len(labels))
+	for name, value := range constLabels {
+		merged[name] = value
+	}
+	for name, value := range labels {
+		merged[name] = value
+	}
+	return merged
 }
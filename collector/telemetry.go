@@ -0,0 +1,207 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// reasons of failed requests to the stats endpoint, counted in the scrape failures metric
+const (
+	failureDial    = "dial"
+	failureTimeout = "timeout"
+	failureStatus  = "status"
+	failureRead    = "read"
+	failureDecode  = "decode"
+)
+
+var failureReasons = []string{failureDial, failureTimeout, failureStatus, failureRead, failureDecode}
+
+// states of a target, exported as an enum in the target state metric
+const (
+	stateOK          = "ok"
+	stateUnreachable = "unreachable"
+	stateBadStatus   = "bad_status"
+	stateDecodeError = "decode_error"
+	stateTypeUnknown = "type_unknown"
+)
+
+var targetStates = []string{stateOK, stateUnreachable, stateBadStatus, stateDecodeError, stateTypeUnknown}
+
+// scrapeError failed request to the stats endpoint with its reason
+type scrapeError struct {
+	reason string
+	err    error
+}
+
+func (e *scrapeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *scrapeError) Unwrap() error {
+	return e.err
+}
+
+// newScrapeError returns err with reason, or with the timeout reason when err is a timeout
+func newScrapeError(reason string, err error) error {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		reason = failureTimeout
+	}
+
+	return &scrapeError{reason: reason, err: err}
+}
+
+// targetState returns the state of a target whose last scrape or beat type detection failed with err.
+// Targets without stats to export, e.g. with a stale snapshot, are unreachable.
+func targetState(err error) string {
+	if err == nil {
+		return stateOK
+	}
+
+	if err == errNoBeatType {
+		return stateTypeUnknown
+	}
+
+	var scrapeErr *scrapeError
+	if errors.As(err, &scrapeErr) {
+		switch scrapeErr.reason {
+		case failureStatus:
+			return stateBadStatus
+		case failureDecode:
+			return stateDecodeError
+		}
+	}
+
+	return stateUnreachable
+}
+
+// scrapeTelemetry exporter side view of the requests to the stats endpoint of a target, failed requests to the
+// root endpoint are counted as well
+type scrapeTelemetry struct {
+	scraped     bool
+	duration    float64
+	size        float64
+	lastSuccess time.Time
+	failures    map[string]float64
+}
+
+// record updates the telemetry with the outcome of a scrape
+func (t *scrapeTelemetry) record(end time.Time, duration float64, size int, err error) {
+	t.scraped = true
+	t.duration = duration
+	t.size = float64(size)
+
+	if err == nil {
+		t.lastSuccess = end
+		return
+	}
+
+	t.recordFailure(err)
+}
+
+// recordFailure counts a failed request by its reason
+func (t *scrapeTelemetry) recordFailure(err error) {
+	var scrapeErr *scrapeError
+	if errors.As(err, &scrapeErr) {
+		if t.failures == nil {
+			t.failures = make(map[string]float64)
+		}
+		t.failures[scrapeErr.reason]++
+	}
+}
+
+// copy returns a copy of the telemetry that does not share the failure counts
+func (t scrapeTelemetry) copy() scrapeTelemetry {
+	failures := make(map[string]float64, len(t.failures))
+	for reason, count := range t.failures {
+		failures[reason] = count
+	}
+	t.failures = failures
+
+	return t
+}
+
+type telemetryDescs struct {
+	duration      *prometheus.Desc
+	size          *prometheus.Desc
+	lastSuccess   *prometheus.Desc
+	failures      *prometheus.Desc
+	up            *prometheus.Desc
+	state         *prometheus.Desc
+	unmappedPaths *prometheus.Desc
+	missingPaths  *prometheus.Desc
+}
+
+func newTelemetryDescs(name string, constLabels prometheus.Labels) telemetryDescs {
+	return telemetryDescs{
+		duration: prometheus.NewDesc(
+			prometheus.BuildFQName(name, "target", "scrape_duration_seconds"),
+			"Duration of the last request to the stats endpoint of the target",
+			nil, constLabels),
+		size: prometheus.NewDesc(
+			prometheus.BuildFQName(name, "target", "scrape_response_size_bytes"),
+			"Size of the last response of the stats endpoint of the target",
+			nil, constLabels),
+		lastSuccess: prometheus.NewDesc(
+			prometheus.BuildFQName(name, "target", "last_success_timestamp_seconds"),
+			"Time of the last successful scrape of the target",
+			nil, constLabels),
+		failures: prometheus.NewDesc(
+			prometheus.BuildFQName(name, "target", "scrape_failures_total"),
+			"Failed requests to the stats and root endpoints of the target by reason",
+			[]string{"reason"}, constLabels),
+		up: prometheus.NewDesc(
+			prometheus.BuildFQName(name, "target", "up"),
+			"Whether the last scrape of the target succeeded, whatever its beat type",
+			[]string{"beat"}, constLabels),
+		state: prometheus.NewDesc(
+			prometheus.BuildFQName(name, "target", "state"),
+			"State of the target, 1 for the current one of ok, unreachable, bad_status, decode_error and type_unknown",
+			[]string{"state"}, constLabels),
+		unmappedPaths: prometheus.NewDesc(
+			prometheus.BuildFQName(name, "target", "schema_unmapped_paths"),
+			"Numeric and boolean values in the last stats of the target that no metric mapping covers",
+			nil, constLabels),
+		missingPaths: prometheus.NewDesc(
+			prometheus.BuildFQName(name, "target", "schema_missing_paths"),
+			"Paths of the metric mappings of the target without a value in its last stats",
+			nil, constLabels),
+	}
+}
+
+// collect sends the telemetry metrics, the failure counts are sent for every reason
+func (t scrapeTelemetry) collect(descs telemetryDescs, ch chan<- prometheus.Metric) {
+	if t.scraped {
+		ch <- prometheus.MustNewConstMetric(descs.duration, prometheus.GaugeValue, t.duration)
+		ch <- prometheus.MustNewConstMetric(descs.size, prometheus.GaugeValue, t.size)
+	}
+
+	if !t.lastSuccess.IsZero() {
+		ch <- prometheus.MustNewConstMetric(descs.lastSuccess, prometheus.GaugeValue, float64(t.lastSuccess.UnixNano())/1e9)
+	}
+
+	for _, reason := range failureReasons {
+		ch <- prometheus.MustNewConstMetric(descs.failures, prometheus.CounterValue, t.failures[reason], reason)
+	}
+}
+
+// collectState sends the target up metric and the target state enum, every state is sent with the current one set to 1
+func collectState(descs telemetryDescs, beat string, state string, ch chan<- prometheus.Metric) {
+	up := 0.0
+	if state == stateOK {
+		up = 1
+	}
+	ch <- prometheus.MustNewConstMetric(descs.up, prometheus.GaugeValue, up, beat)
+
+	for _, s := range targetStates {
+		value := 0.0
+		if s == state {
+			value = 1
+		}
+		ch <- prometheus.MustNewConstMetric(descs.state, prometheus.GaugeValue, value, s)
+	}
+}
@@ -4,8 +4,9 @@ go 1.16
 
 require (
 	github.com/prometheus/client_golang v1.11.0
+	github.com/prometheus/client_model v0.2.0
 	github.com/prometheus/common v0.32.0
 	github.com/sirupsen/logrus v1.8.1
 	golang.org/x/sys v0.0.0-20211020174200-9d6173849985
-
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,667 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/70k10/beat-exporter/collector"
+)
+
+const (
+	// DefaultTimeout is used for targets that do not set their own timeout
+	DefaultTimeout = 10 * time.Second
+
+	// DefaultRefreshInterval is used for discovery configurations that do not set their own interval
+	DefaultRefreshInterval = 30 * time.Second
+
+	// DefaultDockerHost is the Docker Engine API socket queried by docker discovery
+	DefaultDockerHost = "unix:///var/run/docker.sock"
+
+	// DefaultDockerPortLabel is the container label holding the port of the beat http endpoint
+	DefaultDockerPortLabel = "beat-exporter.port"
+
+	// DefaultBeatConfigFiles matches the configuration files of beats installed from packages
+	DefaultBeatConfigFiles = "/etc/*beat/*beat.yml"
+
+	serviceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	serviceAccountCAFile    = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+)
+
+var (
+	labelNameRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+	// reservedLabelNames are set by the exporter on the metrics of every target, the labels of the metric
+	// mappings are checked with CheckLabels once they are loaded
+	reservedLabelNames = map[string]bool{
+		"collector": true,
+		"beat":      true,
+		"version":   true,
+		"reason":    true,
+		"state":     true,
+	}
+)
+
+// Config beat exporter configuration file structure
+type Config struct {
+	Global    GlobalConfig      `yaml:"global"`
+	Targets   []Target          `yaml:"targets"`
+	Modules   map[string]Module `yaml:"modules"`
+	Discovery DiscoveryConfig   `yaml:"discovery"`
+}
+
+// GlobalConfig holds the defaults applied to every target
+type GlobalConfig struct {
+	Timeout        time.Duration `yaml:"timeout"`
+	LabelTemplates `yaml:",inline"`
+	Polling        `yaml:",inline"`
+}
+
+// Target a single beat to collect stats from
+type Target struct {
+	URI     string        `yaml:"uri"`
+	Label   string        `yaml:"label"`
+	Timeout time.Duration `yaml:"timeout"`
+	Auth    `yaml:",inline"`
+	TLS     TLSConfig `yaml:"tls_config"`
+
+	// Labels are added to every metric of the target
+	Labels         map[string]string `yaml:"labels,omitempty"`
+	LabelTemplates `yaml:",inline"`
+	Polling        `yaml:",inline"`
+}
+
+// Polling scrapes a target in the background every poll interval and serves its metrics from the last snapshot,
+// snapshots older than max age are not exported. Targets are scraped on every collection without a poll interval.
+type Polling struct {
+	PollInterval time.Duration `yaml:"poll_interval,omitempty"`
+	MaxAge       time.Duration `yaml:"max_age,omitempty"`
+}
+
+// LabelTemplates render the collector label and extra labels of a target from the info of the detected beat,
+// the collector template is only used for targets without a label
+type LabelTemplates struct {
+	CollectorTemplate string            `yaml:"collector_template,omitempty"`
+	TemplateLabels    map[string]string `yaml:"template_labels,omitempty"`
+}
+
+// DiscoveryConfig lists the mechanisms finding targets at runtime
+type DiscoveryConfig struct {
+	File       []FileSDConfig       `yaml:"file"`
+	Docker     []DockerSDConfig     `yaml:"docker"`
+	Kubernetes []KubernetesSDConfig `yaml:"kubernetes"`
+	BeatConfig []BeatConfigSDConfig `yaml:"beat_config"`
+}
+
+// FileSDConfig watches Prometheus file_sd formatted JSON or YAML files for targets
+type FileSDConfig struct {
+	Files           []string      `yaml:"files"`
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+}
+
+// DockerSDConfig discovers beat containers through the Docker Engine API
+type DockerSDConfig struct {
+	Host            string        `yaml:"host"`
+	PortLabel       string        `yaml:"port_label"`
+	Network         string        `yaml:"network"`
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+}
+
+// KubernetesSDConfig discovers annotated pods through the Kubernetes API, in-cluster when APIServer is empty
+type KubernetesSDConfig struct {
+	APIServer          string `yaml:"api_server"`
+	Namespace          string `yaml:"namespace"`
+	LabelSelector      string `yaml:"label_selector"`
+	BearerTokenFile    string `yaml:"bearer_token_file"`
+	CAFile             string `yaml:"ca_file"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// BeatConfigSDConfig reads the http settings of local beats from their configuration files
+type BeatConfigSDConfig struct {
+	Files           []string      `yaml:"files"`
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+}
+
+// Module preset applied to targets scraped through the /probe endpoint
+type Module struct {
+	Timeout time.Duration `yaml:"timeout"`
+	Auth    `yaml:",inline"`
+	TLS     TLSConfig `yaml:"tls_config"`
+
+	// AllowedTargets limits the module to these hosts, host:port pairs or URI prefixes, any target when empty
+	AllowedTargets []string `yaml:"allowed_targets"`
+}
+
+// Auth credentials and headers sent with every request to a target, files are re-read when they change
+type Auth struct {
+	BasicAuth       *BasicAuth        `yaml:"basic_auth,omitempty"`
+	BearerToken     string            `yaml:"bearer_token,omitempty"`
+	BearerTokenFile string            `yaml:"bearer_token_file,omitempty"`
+	Headers         map[string]string `yaml:"headers,omitempty"`
+}
+
+// BasicAuth HTTP basic authentication credentials
+type BasicAuth struct {
+	Username     string `yaml:"username"`
+	Password     string `yaml:"password,omitempty"`
+	PasswordFile string `yaml:"password_file,omitempty"`
+}
+
+// TLSConfig client TLS settings of a target, the CA and certificate files are reloaded when they change
+type TLSConfig struct {
+	CAFile             string `yaml:"ca_file,omitempty"`
+	CertFile           string `yaml:"cert_file,omitempty"`
+	KeyFile            string `yaml:"key_file,omitempty"`
+	ServerName         string `yaml:"server_name,omitempty"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify,omitempty"`
+}
+
+// Load reads and validates the configuration file at path
+func Load(path string, defaultTimeout time.Duration) (*Config, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := Parse(content, defaultTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return cfg, nil
+}
+
+// Parse parses and validates a YAML configuration
+func Parse(content []byte, defaultTimeout time.Duration) (*Config, error) {
+	cfg := &Config{}
+
+	err := yaml.UnmarshalStrict(content, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Global.Timeout == 0 {
+		cfg.Global.Timeout = defaultTimeout
+	}
+
+	for i := range cfg.Targets {
+		if cfg.Targets[i].Timeout == 0 {
+			cfg.Targets[i].Timeout = cfg.Global.Timeout
+		}
+	}
+
+	for name, module := range cfg.Modules {
+		if module.Timeout == 0 {
+			module.Timeout = cfg.Global.Timeout
+			cfg.Modules[name] = module
+		}
+	}
+
+	for i := range cfg.Discovery.File {
+		if cfg.Discovery.File[i].RefreshInterval == 0 {
+			cfg.Discovery.File[i].RefreshInterval = DefaultRefreshInterval
+		}
+	}
+
+	for i := range cfg.Discovery.Docker {
+		dockerSD := &cfg.Discovery.Docker[i]
+		if dockerSD.Host == "" {
+			dockerSD.Host = DefaultDockerHost
+		}
+		if dockerSD.PortLabel == "" {
+			dockerSD.PortLabel = DefaultDockerPortLabel
+		}
+		if dockerSD.RefreshInterval == 0 {
+			dockerSD.RefreshInterval = DefaultRefreshInterval
+		}
+	}
+
+	for i := range cfg.Discovery.Kubernetes {
+		kubernetesSD := &cfg.Discovery.Kubernetes[i]
+		if kubernetesSD.APIServer == "" {
+			if kubernetesSD.BearerTokenFile == "" {
+				kubernetesSD.BearerTokenFile = serviceAccountTokenFile
+			}
+			if kubernetesSD.CAFile == "" {
+				kubernetesSD.CAFile = serviceAccountCAFile
+			}
+		}
+	}
+
+	for i := range cfg.Discovery.BeatConfig {
+		beatConfigSD := &cfg.Discovery.BeatConfig[i]
+		if len(beatConfigSD.Files) == 0 {
+			beatConfigSD.Files = []string{DefaultBeatConfigFiles}
+		}
+		if beatConfigSD.RefreshInterval == 0 {
+			beatConfigSD.RefreshInterval = DefaultRefreshInterval
+		}
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// FromURIFlag builds targets from the -beat.uri shorthand syntax:
+// comma-separated URIs, each optionally followed by ";label"
+func FromURIFlag(beatURI string, timeout time.Duration) []Target {
+	var targets []Target
+
+	for _, URI := range strings.Split(beatURI, ",") {
+		if len(URI) > 0 {
+			URI, label := parseCollectorLabel(URI)
+			targets = append(targets, Target{
+				URI:     URI,
+				Label:   label,
+				Timeout: timeout,
+			})
+		}
+	}
+
+	return targets
+}
+
+// Validate checks the configuration for errors
+func (c *Config) Validate() error {
+	err := c.Global.LabelTemplates.Validate()
+	if err != nil {
+		return fmt.Errorf("global: %v", err)
+	}
+
+	err = c.Global.Polling.Validate()
+	if err != nil {
+		return fmt.Errorf("global: %v", err)
+	}
+
+	for i, target := range c.Targets {
+		err := target.Validate()
+		if err != nil {
+			return fmt.Errorf("targets[%d]: %v", i, err)
+		}
+	}
+
+	for name, module := range c.Modules {
+		err := module.Validate()
+		if err != nil {
+			return fmt.Errorf("modules[%s]: %v", name, err)
+		}
+	}
+
+	for i, fileSD := range c.Discovery.File {
+		err := fileSD.Validate()
+		if err != nil {
+			return fmt.Errorf("discovery.file[%d]: %v", i, err)
+		}
+	}
+
+	for i, dockerSD := range c.Discovery.Docker {
+		err := dockerSD.Validate()
+		if err != nil {
+			return fmt.Errorf("discovery.docker[%d]: %v", i, err)
+		}
+	}
+
+	for i, kubernetesSD := range c.Discovery.Kubernetes {
+		err := kubernetesSD.Validate()
+		if err != nil {
+			return fmt.Errorf("discovery.kubernetes[%d]: %v", i, err)
+		}
+	}
+
+	for i, beatConfigSD := range c.Discovery.BeatConfig {
+		err := FileSDConfig(beatConfigSD).Validate()
+		if err != nil {
+			return fmt.Errorf("discovery.beat_config[%d]: %v", i, err)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the file discovery configuration for errors
+func (c FileSDConfig) Validate() error {
+	if len(c.Files) == 0 {
+		return fmt.Errorf("files are required")
+	}
+
+	for _, pattern := range c.Files {
+		_, err := filepath.Match(pattern, "")
+		if err != nil {
+			return fmt.Errorf("invalid file pattern %q: %v", pattern, err)
+		}
+	}
+
+	if c.RefreshInterval < 0 {
+		return fmt.Errorf("refresh_interval must not be negative")
+	}
+
+	return nil
+}
+
+// Validate checks the docker discovery configuration for errors
+func (c DockerSDConfig) Validate() error {
+	hostURL, err := url.Parse(c.Host)
+	if err != nil {
+		return fmt.Errorf("invalid host %q: %v", c.Host, err)
+	}
+
+	switch hostURL.Scheme {
+	case "unix", "tcp", "http":
+	default:
+		return fmt.Errorf("host %q has unsupported scheme %q", c.Host, hostURL.Scheme)
+	}
+
+	if c.RefreshInterval < 0 {
+		return fmt.Errorf("refresh_interval must not be negative")
+	}
+
+	return nil
+}
+
+// Validate checks the kubernetes discovery configuration for errors
+func (c KubernetesSDConfig) Validate() error {
+	if c.APIServer == "" {
+		return nil
+	}
+
+	serverURL, err := url.Parse(c.APIServer)
+	if err != nil {
+		return fmt.Errorf("invalid api_server %q: %v", c.APIServer, err)
+	}
+
+	if serverURL.Scheme != "http" && serverURL.Scheme != "https" {
+		return fmt.Errorf("api_server %q has unsupported scheme %q", c.APIServer, serverURL.Scheme)
+	}
+
+	return nil
+}
+
+// Validate checks the module for errors
+func (m Module) Validate() error {
+	if m.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+
+	err := m.Auth.Validate()
+	if err != nil {
+		return err
+	}
+
+	for _, allowed := range m.AllowedTargets {
+		if allowed == "" {
+			return fmt.Errorf("allowed_targets must not contain empty entries")
+		}
+
+		if strings.Contains(allowed, "://") {
+			prefixURL, err := url.Parse(allowed)
+			if err != nil || prefixURL.Host == "" {
+				return fmt.Errorf("allowed target %q is not a valid URI prefix", allowed)
+			}
+		}
+	}
+
+	// whoever reaches /probe picks the target, credentials must not go to any host
+	if m.SendsCredentials() && len(m.AllowedTargets) == 0 {
+		return fmt.Errorf("allowed_targets is required when the module sends credentials")
+	}
+
+	return m.TLS.Validate()
+}
+
+// SendsCredentials reports whether requests with the module carry credentials, custom headers or a client certificate
+func (m Module) SendsCredentials() bool {
+	return m.BasicAuth != nil || m.BearerToken != "" || m.BearerTokenFile != "" || len(m.Headers) != 0 || m.TLS.CertFile != ""
+}
+
+// Allows reports whether the module may be used for the target URL. Hosts and host:port pairs match the host of the
+// URL, URI prefixes its scheme, host and the leading segments of its path.
+func (m Module) Allows(targetURL *url.URL) bool {
+	if len(m.AllowedTargets) == 0 {
+		return true
+	}
+
+	for _, allowed := range m.AllowedTargets {
+		if !strings.Contains(allowed, "://") {
+			if strings.EqualFold(allowed, targetURL.Host) || strings.EqualFold(allowed, targetURL.Hostname()) {
+				return true
+			}
+			continue
+		}
+
+		prefixURL, err := url.Parse(allowed)
+		if err != nil {
+			continue
+		}
+		if prefixURL.Scheme == targetURL.Scheme && strings.EqualFold(prefixURL.Host, targetURL.Host) &&
+			hasPathPrefix(targetURL.Path, prefixURL.Path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasPathPrefix reports whether path starts with the whole segments of prefix, an empty path is "/"
+func hasPathPrefix(path string, prefix string) bool {
+	if path == "" {
+		path = "/"
+	}
+
+	prefix = strings.TrimSuffix(prefix, "/")
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// Validate checks the credentials for errors
+func (a Auth) Validate() error {
+	bearer := a.BearerToken != "" || a.BearerTokenFile != ""
+
+	if a.BasicAuth != nil && bearer {
+		return fmt.Errorf("at most one of basic_auth and bearer_token must be configured")
+	}
+
+	if a.BearerToken != "" && a.BearerTokenFile != "" {
+		return fmt.Errorf("at most one of bearer_token and bearer_token_file must be configured")
+	}
+
+	if a.BasicAuth != nil {
+		if a.BasicAuth.Username == "" {
+			return fmt.Errorf("basic_auth requires a username")
+		}
+
+		if a.BasicAuth.Password != "" && a.BasicAuth.PasswordFile != "" {
+			return fmt.Errorf("at most one of basic_auth password and password_file must be configured")
+		}
+	}
+
+	for name := range a.Headers {
+		if http.CanonicalHeaderKey(name) == "Authorization" && (a.BasicAuth != nil || bearer) {
+			return fmt.Errorf("the Authorization header conflicts with basic_auth and bearer_token")
+		}
+	}
+
+	return nil
+}
+
+// validateLabels checks that labels are valid Prometheus label names that do not clash with the collector labels
+func validateLabels(labels map[string]string) error {
+	for name := range labels {
+		if !labelNameRE.MatchString(name) || strings.HasPrefix(name, "__") {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+
+		if reservedLabelNames[name] {
+			return fmt.Errorf("label name %q is reserved", name)
+		}
+	}
+
+	return nil
+}
+
+// CheckLabels checks that the labels of the target and of its label templates are none of mappingLabels, the
+// labels set by the metric mappings, which would replace them
+func (t Target) CheckLabels(mappingLabels []string) error {
+	for _, name := range mappingLabels {
+		if _, ok := t.Labels[name]; ok {
+			return fmt.Errorf("label name %q is set by the metric mappings", name)
+		}
+		if _, ok := t.TemplateLabels[name]; ok {
+			return fmt.Errorf("template label name %q is set by the metric mappings", name)
+		}
+	}
+
+	return nil
+}
+
+// CheckLabels checks the labels of the targets and of the global label templates against mappingLabels, the
+// labels set by the metric mappings
+func (c *Config) CheckLabels(mappingLabels []string) error {
+	err := Target{LabelTemplates: c.Global.LabelTemplates}.CheckLabels(mappingLabels)
+	if err != nil {
+		return fmt.Errorf("global: %v", err)
+	}
+
+	for i, target := range c.Targets {
+		err = target.CheckLabels(mappingLabels)
+		if err != nil {
+			return fmt.Errorf("targets[%d]: %v", i, err)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks that the templates parse and only use fields of the beat info
+func (t LabelTemplates) Validate() error {
+	err := validateLabels(t.TemplateLabels)
+	if err != nil {
+		return err
+	}
+
+	_, err = collector.NewLabelTemplates(t.CollectorTemplate, t.TemplateLabels)
+	return err
+}
+
+// Validate checks the intervals for errors
+func (p Polling) Validate() error {
+	if p.PollInterval < 0 || p.MaxAge < 0 {
+		return fmt.Errorf("poll_interval and max_age must not be negative")
+	}
+
+	return nil
+}
+
+// WithDefaults returns the target with the label templates and polling settings of global when it sets none of
+// its own. The max age of polled targets defaults to three poll intervals.
+func (t Target) WithDefaults(global GlobalConfig) Target {
+	if t.Label == "" && t.CollectorTemplate == "" {
+		t.CollectorTemplate = global.CollectorTemplate
+	}
+
+	if t.TemplateLabels == nil {
+		t.TemplateLabels = global.TemplateLabels
+	}
+
+	if t.PollInterval == 0 {
+		t.PollInterval = global.PollInterval
+	}
+
+	if t.MaxAge == 0 {
+		t.MaxAge = global.MaxAge
+	}
+
+	if t.MaxAge == 0 {
+		t.MaxAge = 3 * t.PollInterval
+	}
+
+	return t
+}
+
+// Validate checks the TLS settings for errors
+func (c TLSConfig) Validate() error {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("cert_file and key_file must be configured together")
+	}
+
+	return nil
+}
+
+// Validate checks the target for errors
+func (t Target) Validate() error {
+	if t.URI == "" {
+		return fmt.Errorf("uri is required")
+	}
+
+	parsedURL, err := url.Parse(t.URI)
+	if err != nil {
+		return fmt.Errorf("invalid uri %q: %v", t.URI, err)
+	}
+
+	switch parsedURL.Scheme {
+	case "http", "https":
+		if parsedURL.Host == "" {
+			return fmt.Errorf("uri %q has no host", t.URI)
+		}
+	case "unix":
+		if parsedURL.Path == "" {
+			return fmt.Errorf("uri %q has no socket path", t.URI)
+		}
+	default:
+		return fmt.Errorf("uri %q has unsupported scheme %q", t.URI, parsedURL.Scheme)
+	}
+
+	if t.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+
+	err = t.Auth.Validate()
+	if err != nil {
+		return err
+	}
+
+	err = validateLabels(t.Labels)
+	if err != nil {
+		return err
+	}
+
+	err = t.LabelTemplates.Validate()
+	if err != nil {
+		return err
+	}
+
+	err = t.Polling.Validate()
+	if err != nil {
+		return err
+	}
+
+	for name := range t.TemplateLabels {
+		if _, ok := t.Labels[name]; ok {
+			return fmt.Errorf("label %q is set in both labels and template_labels", name)
+		}
+	}
+
+	if t.TLS != (TLSConfig{}) && parsedURL.Scheme != "https" {
+		return fmt.Errorf("tls_config requires an https uri")
+	}
+
+	return t.TLS.Validate()
+}
+
+func parseCollectorLabel(URI string) (string, string) {
+	splitURIandLabel := strings.Split(URI, ";")
+	if len(splitURIandLabel) > 1 && len(splitURIandLabel[1]) > 0 {
+		return splitURIandLabel[0], splitURIandLabel[1]
+	}
+	return splitURIandLabel[0], ""
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestModuleAllows(t *testing.T) {
+	for _, test := range []struct {
+		allowed []string
+		target  string
+		allows  bool
+	}{
+		{allowed: nil, target: "http://any:5066", allows: true},
+		{allowed: []string{"host1"}, target: "https://host1:5066", allows: true},
+		{allowed: []string{"host1:5066"}, target: "https://HOST1:5066/stats", allows: true},
+		{allowed: []string{"host1:5066"}, target: "https://host1:5067", allows: false},
+		{allowed: []string{"host1"}, target: "https://host10:5066", allows: false},
+		{allowed: []string{"https://host2:5066/"}, target: "https://host2:5066", allows: true},
+		{allowed: []string{"https://host2:5066/"}, target: "https://host2:5066/", allows: true},
+		{allowed: []string{"https://host2:5066"}, target: "https://host2:5066/stats", allows: true},
+		{allowed: []string{"https://host2:5066/"}, target: "http://host2:5066", allows: false},
+		{allowed: []string{"https://host2:5066/"}, target: "https://host2:5067", allows: false},
+		{allowed: []string{"https://host2:5066/api"}, target: "https://host2:5066/api", allows: true},
+		{allowed: []string{"https://host2:5066/api"}, target: "https://host2:5066/api/stats", allows: true},
+		{allowed: []string{"https://host2:5066/api/"}, target: "https://host2:5066/api", allows: true},
+		{allowed: []string{"https://host2:5066/api"}, target: "https://host2:5066/apievil", allows: false},
+		{allowed: []string{"https://host2:5066/api"}, target: "https://host2:5066", allows: false},
+		{allowed: []string{"host3", "https://host2:5066/api"}, target: "https://host3:5066/other", allows: true},
+	} {
+		targetURL, err := url.Parse(test.target)
+		if err != nil {
+			t.Fatalf("parse %q: %v", test.target, err)
+		}
+
+		module := Module{AllowedTargets: test.allowed}
+		if allows := module.Allows(targetURL); allows != test.allows {
+			t.Errorf("allowed targets %q, target %q: got %v, want %v", test.allowed, test.target, allows, test.allows)
+		}
+	}
+}
+
+func TestModuleValidateCredentials(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		module Module
+		valid  bool
+	}{
+		{name: "no credentials", module: Module{}, valid: true},
+		{name: "basic auth", module: Module{Auth: Auth{BasicAuth: &BasicAuth{Username: "user", Password: "secret"}}}, valid: false},
+		{name: "bearer token", module: Module{Auth: Auth{BearerToken: "abc123"}}, valid: false},
+		{name: "client certificate", module: Module{TLS: TLSConfig{CertFile: "client.crt", KeyFile: "client.key"}}, valid: false},
+		{name: "allowed targets", module: Module{Auth: Auth{BearerToken: "abc123"}, AllowedTargets: []string{"host1:5066"}}, valid: true},
+	} {
+		err := test.module.Validate()
+		if (err == nil) != test.valid {
+			t.Errorf("%s: got error %v, expected valid %v", test.name, err, test.valid)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package discovery
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+
+	"github.com/70k10/beat-exporter/internal/config"
+)
+
+const (
+	// defaults of the beats http endpoint settings
+	defaultBeatHTTPHost = "localhost"
+	defaultBeatHTTPPort = 5066
+)
+
+type beatConfigDiscoverer struct {
+	cfg     config.BeatConfigSDConfig
+	timeout time.Duration
+
+	// last readable target per file, kept when a file fails to parse
+	fileTargets map[string]*config.Target
+}
+
+// NewBeatConfigDiscoverer discovers local beats from the http settings in their configuration files
+func NewBeatConfigDiscoverer(cfg config.BeatConfigSDConfig, timeout time.Duration) Discoverer {
+	return &beatConfigDiscoverer{
+		cfg:         cfg,
+		timeout:     timeout,
+		fileTargets: make(map[string]*config.Target),
+	}
+}
+
+// Run re-reads the beat configuration files every refresh interval
+func (d *beatConfigDiscoverer) Run(stopCh <-chan struct{}, ch chan<- []config.Target) {
+	runRefresh(stopCh, ch, d.cfg.RefreshInterval, d.refresh)
+}
+
+func (d *beatConfigDiscoverer) refresh() ([]config.Target, error) {
+	files := make(map[string]struct{})
+
+	for _, pattern := range d.cfg.Files {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			log.WithFields(log.Fields{"pattern": pattern, "err": err}).Errorf("Invalid beat config pattern: %v", err)
+			continue
+		}
+
+		for _, file := range matches {
+			files[file] = struct{}{}
+		}
+	}
+
+	for file := range d.fileTargets {
+		if _, ok := files[file]; !ok {
+			delete(d.fileTargets, file)
+		}
+	}
+
+	for file := range files {
+		target, err := d.readFile(file)
+		if err != nil {
+			log.WithFields(log.Fields{"file": file, "err": err}).Errorf("Failed to read beat config file: %v", err)
+			continue
+		}
+
+		d.fileTargets[file] = target
+	}
+
+	names := make([]string, 0, len(d.fileTargets))
+	for file := range d.fileTargets {
+		names = append(names, file)
+	}
+	sort.Strings(names)
+
+	var targets []config.Target
+	for _, file := range names {
+		if d.fileTargets[file] != nil {
+			targets = append(targets, *d.fileTargets[file])
+		}
+	}
+
+	return targets, nil
+}
+
+// readFile returns the target of the beat configured in file, nil when its http endpoint is disabled
+func (d *beatConfigDiscoverer) readFile(file string) (*config.Target, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := map[interface{}]interface{}{}
+	err = yaml.Unmarshal(content, &settings)
+	if err != nil {
+		return nil, err
+	}
+
+	enabled, _ := beatSetting(settings, "http.enabled")
+	if enabledBool, err := strconv.ParseBool(fmt.Sprint(enabled)); err != nil || !enabledBool {
+		return nil, nil
+	}
+
+	host := defaultBeatHTTPHost
+	if value, ok := beatSetting(settings, "http.host"); ok {
+		host = fmt.Sprint(value)
+	}
+
+	port := defaultBeatHTTPPort
+	if value, ok := beatSetting(settings, "http.port"); ok {
+		port, err = strconv.Atoi(fmt.Sprint(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid http.port %v", value)
+		}
+	}
+
+	URI := host
+	if !strings.HasPrefix(host, "unix://") {
+		// beats listen on all interfaces for 0.0.0.0, reach them on localhost
+		if host == "0.0.0.0" || host == "::" || host == "" {
+			host = defaultBeatHTTPHost
+		}
+		URI = "http://" + net.JoinHostPort(host, strconv.Itoa(port))
+	}
+
+	// the collector label is the beat name, the file name when it is not set
+	label := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	if name, ok := beatSetting(settings, "name"); ok {
+		label = fmt.Sprint(name)
+	}
+
+	target := &config.Target{
+		URI:     URI,
+		Label:   label,
+		Timeout: d.timeout,
+	}
+
+	err = target.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return target, nil
+}
+
+// beatSetting returns the setting at the dotted path, beats accept it nested or written with dotted keys
+func beatSetting(settings map[interface{}]interface{}, path string) (interface{}, bool) {
+	parts := strings.Split(path, ".")
+
+	for i := len(parts); i > 0; i-- {
+		value, ok := settings[strings.Join(parts[:i], ".")]
+		if !ok {
+			continue
+		}
+
+		if i == len(parts) {
+			return value, true
+		}
+
+		if nested, ok := value.(map[interface{}]interface{}); ok {
+			if value, ok := beatSetting(nested, strings.Join(parts[i:], ".")); ok {
+				return value, true
+			}
+		}
+	}
+
+	return nil, false
+}
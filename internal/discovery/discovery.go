@@ -0,0 +1,161 @@
+package discovery
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/70k10/beat-exporter/internal/config"
+)
+
+// Discoverer finds targets at runtime
+type Discoverer interface {
+	// Run sends the complete list of targets on ch whenever it changes, until stopCh is closed
+	Run(stopCh <-chan struct{}, ch chan<- []config.Target)
+}
+
+// SyncFunc replaces the targets of a source
+type SyncFunc func(source string, targets []config.Target)
+
+// Manager runs the discoverers of the current configuration and forwards their targets to sync
+type Manager struct {
+	mtx     sync.Mutex
+	sync    SyncFunc
+	cfg     config.DiscoveryConfig
+	timeout time.Duration
+	stopCh  chan struct{}
+	sources map[string]struct{}
+}
+
+// NewManager constructor
+func NewManager(sync SyncFunc) *Manager {
+	return &Manager{
+		sync:    sync,
+		sources: make(map[string]struct{}),
+	}
+}
+
+// ApplyConfig restarts the discoverers when their configuration changed. Targets of sources that
+// still exist are kept until the restarted discoverer reports, removed sources are cleared.
+func (m *Manager) ApplyConfig(cfg config.DiscoveryConfig, timeout time.Duration) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if m.stopCh != nil && reflect.DeepEqual(cfg, m.cfg) && timeout == m.timeout {
+		return
+	}
+
+	m.stop()
+	m.cfg = cfg
+	m.timeout = timeout
+	m.stopCh = make(chan struct{})
+
+	discoverers := newDiscoverers(cfg, timeout)
+
+	for source := range m.sources {
+		if _, ok := discoverers[source]; !ok {
+			m.sync(source, nil)
+			delete(m.sources, source)
+		}
+	}
+
+	for source, discoverer := range discoverers {
+		m.sources[source] = struct{}{}
+		go m.run(source, discoverer, m.stopCh)
+	}
+}
+
+// Stop stops all discoverers
+func (m *Manager) Stop() {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.stop()
+}
+
+func (m *Manager) stop() {
+	if m.stopCh != nil {
+		close(m.stopCh)
+		m.stopCh = nil
+	}
+}
+
+func (m *Manager) run(source string, discoverer Discoverer, stopCh chan struct{}) {
+	ch := make(chan []config.Target)
+	go discoverer.Run(stopCh, ch)
+
+	for {
+		select {
+		case targets := <-ch:
+			m.sync(source, targets)
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// newDiscoverers creates a discoverer per discovery configuration, keyed by a stable source name
+func newDiscoverers(cfg config.DiscoveryConfig, timeout time.Duration) map[string]Discoverer {
+	discoverers := make(map[string]Discoverer)
+
+	for i, fileSD := range cfg.File {
+		discoverers[fmt.Sprintf("file/%d", i)] = NewFileDiscoverer(fileSD, timeout)
+	}
+
+	for i, dockerSD := range cfg.Docker {
+		discoverers[fmt.Sprintf("docker/%d", i)] = NewDockerDiscoverer(dockerSD, timeout)
+	}
+
+	for i, kubernetesSD := range cfg.Kubernetes {
+		discoverers[fmt.Sprintf("kubernetes/%d", i)] = NewKubernetesDiscoverer(kubernetesSD, timeout)
+	}
+
+	for i, beatConfigSD := range cfg.BeatConfig {
+		discoverers[fmt.Sprintf("beat_config/%d", i)] = NewBeatConfigDiscoverer(beatConfigSD, timeout)
+	}
+
+	return discoverers
+}
+
+// runRefresh calls refresh every interval and sends the targets on ch when they changed. The previous
+// targets are kept when refresh fails.
+func runRefresh(stopCh <-chan struct{}, ch chan<- []config.Target, interval time.Duration, refresh func() ([]config.Target, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last []config.Target
+	sent := false
+
+	for {
+		targets, err := refresh()
+		if err != nil {
+			log.WithFields(log.Fields{"err": err}).Errorf("Failed to refresh targets: %v", err)
+		} else if !sent || !reflect.DeepEqual(targets, last) {
+			select {
+			case ch <- targets:
+				last = targets
+				sent = true
+			case <-stopCh:
+				return
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// targetURI turns a host:port address into a http URI, full URIs are kept as they are
+func targetURI(address string) string {
+	if strings.Contains(address, "://") {
+		return address
+	}
+	return "http://" + address
+}
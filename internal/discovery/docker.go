@@ -0,0 +1,170 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/70k10/beat-exporter/internal/config"
+)
+
+const (
+	containerNameLabel  = "container_name"
+	containerImageLabel = "image"
+)
+
+// dockerContainer fields of the Docker Engine API container list used for discovery
+type dockerContainer struct {
+	ID         string            `json:"Id"`
+	Names      []string          `json:"Names"`
+	Image      string            `json:"Image"`
+	Labels     map[string]string `json:"Labels"`
+	HostConfig struct {
+		NetworkMode string `json:"NetworkMode"`
+	} `json:"HostConfig"`
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress string `json:"IPAddress"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+type dockerDiscoverer struct {
+	cfg     config.DockerSDConfig
+	timeout time.Duration
+	client  *http.Client
+	baseURL *url.URL
+}
+
+// NewDockerDiscoverer discovers running containers carrying the port label through the Docker Engine API
+func NewDockerDiscoverer(cfg config.DockerSDConfig, timeout time.Duration) Discoverer {
+	baseURL, _ := url.Parse(cfg.Host)
+	client := &http.Client{
+		Timeout: timeout,
+	}
+
+	switch baseURL.Scheme {
+	case "unix":
+		socketPath := baseURL.Path
+		baseURL = &url.URL{Scheme: "http", Host: "localhost"}
+		client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+			},
+		}
+	case "tcp":
+		baseURL.Scheme = "http"
+	}
+
+	return &dockerDiscoverer{
+		cfg:     cfg,
+		timeout: timeout,
+		client:  client,
+		baseURL: baseURL,
+	}
+}
+
+// Run lists the containers every refresh interval
+func (d *dockerDiscoverer) Run(stopCh <-chan struct{}, ch chan<- []config.Target) {
+	runRefresh(stopCh, ch, d.cfg.RefreshInterval, d.refresh)
+}
+
+func (d *dockerDiscoverer) refresh() ([]config.Target, error) {
+	containers, err := d.listContainers()
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []config.Target
+	for _, container := range containers {
+		port, err := strconv.Atoi(container.Labels[d.cfg.PortLabel])
+		if err != nil {
+			continue
+		}
+
+		host := d.containerAddress(container)
+		if host == "" {
+			continue
+		}
+
+		name := container.ID
+		if len(container.Names) > 0 {
+			name = strings.TrimPrefix(container.Names[0], "/")
+		}
+
+		targets = append(targets, config.Target{
+			URI:     fmt.Sprintf("http://%s", net.JoinHostPort(host, strconv.Itoa(port))),
+			Label:   name,
+			Timeout: d.timeout,
+			Labels: map[string]string{
+				containerNameLabel:  name,
+				containerImageLabel: container.Image,
+			},
+		})
+	}
+
+	return targets, nil
+}
+
+// containerAddress returns the address the beat of the container is reachable at
+func (d *dockerDiscoverer) containerAddress(container dockerContainer) string {
+	if container.HostConfig.NetworkMode == "host" {
+		return "localhost"
+	}
+
+	if d.cfg.Network != "" {
+		return container.NetworkSettings.Networks[d.cfg.Network].IPAddress
+	}
+
+	for _, network := range container.NetworkSettings.Networks {
+		if network.IPAddress != "" {
+			return network.IPAddress
+		}
+	}
+
+	return ""
+}
+
+func (d *dockerDiscoverer) listContainers() ([]dockerContainer, error) {
+	filters, err := json.Marshal(map[string][]string{
+		"label":  {d.cfg.PortLabel},
+		"status": {"running"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	listURL := *d.baseURL
+	listURL.Path = strings.TrimSuffix(listURL.Path, "/") + "/containers/json"
+	listURL.RawQuery = url.Values{"filters": {string(filters)}}.Encode()
+
+	response, err := d.client.Get(listURL.String())
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	bodyBytes, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("docker API status code %d: %s", response.StatusCode, strings.TrimSpace(string(bodyBytes)))
+	}
+
+	var containers []dockerContainer
+	err = json.Unmarshal(bodyBytes, &containers)
+	if err != nil {
+		return nil, err
+	}
+
+	return containers, nil
+}
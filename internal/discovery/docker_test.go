@@ -0,0 +1,195 @@
+package discovery
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/70k10/beat-exporter/internal/config"
+)
+
+const testContainers = `[
+  {
+    "Id": "0a1b2c",
+    "Names": ["/filebeat"],
+    "Image": "docker.elastic.co/beats/filebeat:7.17.0",
+    "Labels": {"beat-exporter.port": "5066"},
+    "HostConfig": {"NetworkMode": "bridge"},
+    "NetworkSettings": {"Networks": {"bridge": {"IPAddress": "172.17.0.2"}}}
+  },
+  {
+    "Id": "3d4e5f",
+    "Names": ["/metricbeat"],
+    "Image": "docker.elastic.co/beats/metricbeat:7.17.0",
+    "Labels": {"beat-exporter.port": "5067"},
+    "HostConfig": {"NetworkMode": "host"},
+    "NetworkSettings": {"Networks": {"host": {"IPAddress": ""}}}
+  },
+  {
+    "Id": "6a7b8c",
+    "Names": ["/heartbeat"],
+    "Image": "docker.elastic.co/beats/heartbeat:7.17.0",
+    "Labels": {"beat-exporter.port": "none"},
+    "HostConfig": {"NetworkMode": "bridge"},
+    "NetworkSettings": {"Networks": {"bridge": {"IPAddress": "172.17.0.4"}}}
+  },
+  {
+    "Id": "9d0e1f",
+    "Names": ["/packetbeat"],
+    "Image": "docker.elastic.co/beats/packetbeat:7.17.0",
+    "Labels": {"beat-exporter.port": "5066"},
+    "HostConfig": {"NetworkMode": "none"},
+    "NetworkSettings": {"Networks": {}}
+  }
+]`
+
+// newDockerServer serves handler on a unix socket in a temporary directory and returns its docker host
+func newDockerServer(t *testing.T, handler http.HandlerFunc) string {
+	socketPath := filepath.Join(t.TempDir(), "docker.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("listen on %s: %v", socketPath, err)
+	}
+
+	server := httptest.NewUnstartedServer(handler)
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+
+	return "unix://" + socketPath
+}
+
+func TestDockerDiscovererRefresh(t *testing.T) {
+	host := newDockerServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/containers/json" {
+			http.NotFound(w, r)
+			return
+		}
+
+		filters := map[string][]string{}
+		err := json.Unmarshal([]byte(r.URL.Query().Get("filters")), &filters)
+		if err != nil {
+			t.Errorf("invalid filters %q: %v", r.URL.Query().Get("filters"), err)
+		}
+		if !reflect.DeepEqual(filters["label"], []string{"beat-exporter.port"}) || !reflect.DeepEqual(filters["status"], []string{"running"}) {
+			t.Errorf("unexpected filters %v", filters)
+		}
+
+		w.Write([]byte(testContainers))
+	})
+
+	d := NewDockerDiscoverer(config.DockerSDConfig{
+		Host:            host,
+		PortLabel:       config.DefaultDockerPortLabel,
+		RefreshInterval: time.Hour,
+	}, 5*time.Second).(*dockerDiscoverer)
+
+	targets, err := d.refresh()
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+
+	expected := []config.Target{
+		{
+			URI:     "http://172.17.0.2:5066",
+			Label:   "filebeat",
+			Timeout: 5 * time.Second,
+			Labels:  map[string]string{"container_name": "filebeat", "image": "docker.elastic.co/beats/filebeat:7.17.0"},
+		},
+		{
+			URI:     "http://localhost:5067",
+			Label:   "metricbeat",
+			Timeout: 5 * time.Second,
+			Labels:  map[string]string{"container_name": "metricbeat", "image": "docker.elastic.co/beats/metricbeat:7.17.0"},
+		},
+	}
+	if !reflect.DeepEqual(targets, expected) {
+		t.Errorf("unexpected targets\n got: %+v\nwant: %+v", targets, expected)
+	}
+}
+
+func TestDockerDiscovererNetwork(t *testing.T) {
+	host := newDockerServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{
+		  "Id": "0a1b2c",
+		  "Names": ["/filebeat"],
+		  "Labels": {"beat-exporter.port": "5066"},
+		  "NetworkSettings": {"Networks": {"bridge": {"IPAddress": "172.17.0.2"}, "beats": {"IPAddress": "10.1.0.2"}}}
+		}]`))
+	})
+
+	d := NewDockerDiscoverer(config.DockerSDConfig{
+		Host:      host,
+		PortLabel: config.DefaultDockerPortLabel,
+		Network:   "beats",
+	}, time.Second).(*dockerDiscoverer)
+
+	targets, err := d.refresh()
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if len(targets) != 1 || targets[0].URI != "http://10.1.0.2:5066" {
+		t.Errorf("expected the address in the configured network, got %+v", targets)
+	}
+}
+
+func TestDockerDiscovererError(t *testing.T) {
+	host := newDockerServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"client version too old"}`, http.StatusBadRequest)
+	})
+
+	d := NewDockerDiscoverer(config.DockerSDConfig{Host: host, PortLabel: config.DefaultDockerPortLabel}, time.Second).(*dockerDiscoverer)
+
+	_, err := d.refresh()
+	if err == nil {
+		t.Fatal("expected an error for a failed container list")
+	}
+}
+
+func TestDockerDiscovererRun(t *testing.T) {
+	host := newDockerServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testContainers))
+	})
+
+	d := NewDockerDiscoverer(config.DockerSDConfig{
+		Host:            host,
+		PortLabel:       config.DefaultDockerPortLabel,
+		RefreshInterval: 10 * time.Millisecond,
+	}, time.Second)
+
+	stopCh := make(chan struct{})
+	ch := make(chan []config.Target)
+	done := make(chan struct{})
+	go func() {
+		d.Run(stopCh, ch)
+		close(done)
+	}()
+
+	select {
+	case targets := <-ch:
+		if len(targets) != 2 {
+			t.Errorf("expected 2 targets, got %+v", targets)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no targets sent")
+	}
+
+	// unchanged containers are not sent again
+	select {
+	case targets := <-ch:
+		t.Errorf("unexpected update %+v", targets)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(stopCh)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after stop")
+	}
+}
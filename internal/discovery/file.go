@@ -0,0 +1,144 @@
+package discovery
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+
+	"github.com/70k10/beat-exporter/internal/config"
+)
+
+// collectorLabel is the file_sd label used as the collector label of its targets
+const collectorLabel = "collector"
+
+// fileSDGroup Prometheus file_sd target group structure
+type fileSDGroup struct {
+	Targets []string          `yaml:"targets"`
+	Labels  map[string]string `yaml:"labels"`
+}
+
+type fileDiscoverer struct {
+	cfg     config.FileSDConfig
+	timeout time.Duration
+
+	// last readable content per file, kept when a file fails to parse
+	fileTargets map[string][]config.Target
+}
+
+// NewFileDiscoverer discovers targets from Prometheus file_sd formatted files
+func NewFileDiscoverer(cfg config.FileSDConfig, timeout time.Duration) Discoverer {
+	return &fileDiscoverer{
+		cfg:         cfg,
+		timeout:     timeout,
+		fileTargets: make(map[string][]config.Target),
+	}
+}
+
+// Run re-reads the files every refresh interval
+func (d *fileDiscoverer) Run(stopCh <-chan struct{}, ch chan<- []config.Target) {
+	runRefresh(stopCh, ch, d.cfg.RefreshInterval, d.refresh)
+}
+
+func (d *fileDiscoverer) refresh() ([]config.Target, error) {
+	files := make(map[string]struct{})
+
+	for _, pattern := range d.cfg.Files {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			log.WithFields(log.Fields{"pattern": pattern, "err": err}).Errorf("Invalid file_sd pattern: %v", err)
+			continue
+		}
+
+		for _, file := range matches {
+			files[file] = struct{}{}
+		}
+	}
+
+	for file := range d.fileTargets {
+		if _, ok := files[file]; !ok {
+			delete(d.fileTargets, file)
+		}
+	}
+
+	for file := range files {
+		targets, err := d.readFile(file)
+		if err != nil {
+			log.WithFields(log.Fields{"file": file, "err": err}).Errorf("Failed to read file_sd file: %v", err)
+			continue
+		}
+
+		d.fileTargets[file] = targets
+	}
+
+	names := make([]string, 0, len(d.fileTargets))
+	for file := range d.fileTargets {
+		names = append(names, file)
+	}
+	sort.Strings(names)
+
+	var targets []config.Target
+	for _, file := range names {
+		targets = append(targets, d.fileTargets[file]...)
+	}
+
+	return targets, nil
+}
+
+func (d *fileDiscoverer) readFile(file string) ([]config.Target, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	// YAML is a superset of JSON, both file_sd formats are parsed the same way
+	var groups []fileSDGroup
+	err = yaml.UnmarshalStrict(content, &groups)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []config.Target
+	for _, group := range groups {
+		labels := groupLabels(group.Labels)
+		for _, address := range group.Targets {
+			target := config.Target{
+				URI:     targetURI(address),
+				Label:   group.Labels[collectorLabel],
+				Timeout: d.timeout,
+				Labels:  labels,
+			}
+
+			err = target.Validate()
+			if err != nil {
+				return nil, err
+			}
+
+			targets = append(targets, target)
+		}
+	}
+
+	return targets, nil
+}
+
+// groupLabels returns the labels of a group added to the metrics of its targets, without the collector label
+// and the internal labels starting with __
+func groupLabels(labels map[string]string) map[string]string {
+	var targetLabels map[string]string
+	for name, value := range labels {
+		if name == collectorLabel || strings.HasPrefix(name, "__") {
+			continue
+		}
+
+		if targetLabels == nil {
+			targetLabels = make(map[string]string, len(labels))
+		}
+		targetLabels[name] = value
+	}
+
+	return targetLabels
+}
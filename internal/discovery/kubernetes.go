@@ -0,0 +1,393 @@
+package discovery
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/70k10/beat-exporter/internal/config"
+)
+
+const (
+	// pod annotations selecting and describing the beat http endpoint
+	portAnnotation   = "beat-exporter/port"
+	pathAnnotation   = "beat-exporter/path"
+	schemeAnnotation = "beat-exporter/scheme"
+
+	namespaceLabel = "namespace"
+	podLabel       = "pod"
+	containerLabel = "container"
+
+	// watchTimeout ends watch requests server side so they are renewed regularly
+	watchTimeout = 5 * time.Minute
+
+	// kubernetesRetryInterval is the wait before listing the pods again after an error
+	kubernetesRetryInterval = 5 * time.Second
+)
+
+// kubernetesPod fields of the Kubernetes pod resource used for discovery
+type kubernetesPod struct {
+	Metadata struct {
+		Name            string            `json:"name"`
+		Namespace       string            `json:"namespace"`
+		UID             string            `json:"uid"`
+		ResourceVersion string            `json:"resourceVersion"`
+		Annotations     map[string]string `json:"annotations"`
+	} `json:"metadata"`
+	Spec struct {
+		Containers []struct {
+			Name  string `json:"name"`
+			Ports []struct {
+				ContainerPort int `json:"containerPort"`
+			} `json:"ports"`
+		} `json:"containers"`
+	} `json:"spec"`
+	Status struct {
+		Phase string `json:"phase"`
+		PodIP string `json:"podIP"`
+	} `json:"status"`
+}
+
+type kubernetesPodList struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Items []kubernetesPod `json:"items"`
+}
+
+type kubernetesWatchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+type kubernetesStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type kubernetesDiscoverer struct {
+	cfg     config.KubernetesSDConfig
+	timeout time.Duration
+	client  *http.Client
+	baseURL *url.URL
+	err     error
+
+	pods map[string]kubernetesPod
+	last []config.Target
+	sent bool
+}
+
+// NewKubernetesDiscoverer discovers pods carrying the port annotation through the Kubernetes API
+func NewKubernetesDiscoverer(cfg config.KubernetesSDConfig, timeout time.Duration) Discoverer {
+	d := &kubernetesDiscoverer{
+		cfg:     cfg,
+		timeout: timeout,
+	}
+
+	apiServer := cfg.APIServer
+	if apiServer == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			d.err = fmt.Errorf("api_server is not set and not running in a cluster")
+			return d
+		}
+		apiServer = "https://" + net.JoinHostPort(host, port)
+	}
+
+	d.baseURL, d.err = url.Parse(apiServer)
+	if d.err != nil {
+		return d
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+	if cfg.CAFile != "" {
+		caCert, err := ioutil.ReadFile(cfg.CAFile)
+		if err != nil {
+			d.err = err
+			return d
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caCert) {
+			d.err = fmt.Errorf("no certificates found in %s", cfg.CAFile)
+			return d
+		}
+	}
+
+	// no client timeout, watch responses are streamed until watchTimeout
+	d.client = &http.Client{
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig,
+		},
+	}
+
+	return d
+}
+
+// Run lists the pods and then watches them for changes, listing again after errors
+func (d *kubernetesDiscoverer) Run(stopCh <-chan struct{}, ch chan<- []config.Target) {
+	if d.err != nil {
+		log.WithFields(log.Fields{"err": d.err}).Errorf("Kubernetes discovery disabled: %v", d.err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		err := d.listAndWatch(ctx, ch)
+		if ctx.Err() != nil {
+			return
+		}
+
+		log.WithFields(log.Fields{"err": err}).Errorf("Kubernetes pod watch failed: %v, listing again in %s", err, kubernetesRetryInterval)
+
+		select {
+		case <-time.After(kubernetesRetryInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (d *kubernetesDiscoverer) listAndWatch(ctx context.Context, ch chan<- []config.Target) error {
+	podList := &kubernetesPodList{}
+	err := d.get(ctx, url.Values{}, d.timeout, func(response *http.Response) error {
+		return json.NewDecoder(response.Body).Decode(podList)
+	})
+	if err != nil {
+		return err
+	}
+
+	d.pods = make(map[string]kubernetesPod, len(podList.Items))
+	for _, pod := range podList.Items {
+		d.pods[pod.Metadata.UID] = pod
+	}
+
+	err = d.send(ctx, ch)
+	if err != nil {
+		return err
+	}
+
+	resourceVersion := podList.Metadata.ResourceVersion
+	for {
+		resourceVersion, err = d.watch(ctx, ch, resourceVersion)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// watch applies pod events until the server ends the watch, it returns the last seen resource version
+func (d *kubernetesDiscoverer) watch(ctx context.Context, ch chan<- []config.Target, resourceVersion string) (string, error) {
+	params := url.Values{
+		"watch":               {"1"},
+		"resourceVersion":     {resourceVersion},
+		"allowWatchBookmarks": {"true"},
+		"timeoutSeconds":      {strconv.Itoa(int(watchTimeout.Seconds()))},
+	}
+
+	err := d.get(ctx, params, 0, func(response *http.Response) error {
+		decoder := json.NewDecoder(response.Body)
+		for {
+			event := &kubernetesWatchEvent{}
+			err := decoder.Decode(event)
+			if err != nil {
+				if err == io.EOF {
+					return nil
+				}
+				return err
+			}
+
+			if event.Type == "ERROR" {
+				status := &kubernetesStatus{}
+				json.Unmarshal(event.Object, status)
+				return fmt.Errorf("watch error %d: %s", status.Code, status.Message)
+			}
+
+			pod := kubernetesPod{}
+			err = json.Unmarshal(event.Object, &pod)
+			if err != nil {
+				return err
+			}
+
+			// bookmarks only carry the resource version
+			resourceVersion = pod.Metadata.ResourceVersion
+
+			switch event.Type {
+			case "ADDED", "MODIFIED":
+				d.pods[pod.Metadata.UID] = pod
+			case "DELETED":
+				delete(d.pods, pod.Metadata.UID)
+			default:
+				continue
+			}
+
+			err = d.send(ctx, ch)
+			if err != nil {
+				return err
+			}
+		}
+	})
+
+	return resourceVersion, err
+}
+
+// get requests the pods resource with params and hands the successful response to handle
+func (d *kubernetesDiscoverer) get(ctx context.Context, params url.Values, timeout time.Duration, handle func(*http.Response) error) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// the API server may be behind a proxy path, resources are relative to it
+	podsURL := *d.baseURL
+	podsURL.RawPath = ""
+	apiPath := strings.TrimSuffix(d.baseURL.Path, "/")
+	if d.cfg.Namespace != "" {
+		podsURL.Path = fmt.Sprintf("%s/api/v1/namespaces/%s/pods", apiPath, d.cfg.Namespace)
+	} else {
+		podsURL.Path = apiPath + "/api/v1/pods"
+	}
+
+	if d.cfg.LabelSelector != "" {
+		params.Set("labelSelector", d.cfg.LabelSelector)
+	}
+	podsURL.RawQuery = params.Encode()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, podsURL.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	// the token file is read on every request, service account tokens are rotated
+	if d.cfg.BearerTokenFile != "" {
+		token, err := ioutil.ReadFile(d.cfg.BearerTokenFile)
+		if err != nil {
+			return err
+		}
+		request.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		bodyBytes, _ := ioutil.ReadAll(response.Body)
+		return fmt.Errorf("kubernetes API status code %d: %s", response.StatusCode, strings.TrimSpace(string(bodyBytes)))
+	}
+
+	return handle(response)
+}
+
+// send sends the targets of the known pods when they changed
+func (d *kubernetesDiscoverer) send(ctx context.Context, ch chan<- []config.Target) error {
+	targets := d.targets()
+	if d.sent && reflect.DeepEqual(targets, d.last) {
+		return nil
+	}
+
+	select {
+	case ch <- targets:
+		d.last = targets
+		d.sent = true
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *kubernetesDiscoverer) targets() []config.Target {
+	var targets []config.Target
+
+	for _, pod := range d.pods {
+		target, ok := d.podTarget(pod)
+		if ok {
+			targets = append(targets, target)
+		}
+	}
+
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Label < targets[j].Label })
+
+	return targets
+}
+
+// podTarget builds the target of a running pod with a valid port annotation
+func (d *kubernetesDiscoverer) podTarget(pod kubernetesPod) (config.Target, bool) {
+	if pod.Status.Phase != "Running" || pod.Status.PodIP == "" {
+		return config.Target{}, false
+	}
+
+	port, err := strconv.Atoi(pod.Metadata.Annotations[portAnnotation])
+	if err != nil {
+		return config.Target{}, false
+	}
+
+	scheme := pod.Metadata.Annotations[schemeAnnotation]
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	path := strings.TrimSuffix(pod.Metadata.Annotations[pathAnnotation], "/")
+	if path != "" && !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	// the container exposing the port, or the only container of the pod
+	container := ""
+	for _, c := range pod.Spec.Containers {
+		for _, p := range c.Ports {
+			if p.ContainerPort == port {
+				container = c.Name
+			}
+		}
+	}
+	if container == "" && len(pod.Spec.Containers) == 1 {
+		container = pod.Spec.Containers[0].Name
+	}
+
+	target := config.Target{
+		URI:     fmt.Sprintf("%s://%s%s", scheme, net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(port)), path),
+		Label:   pod.Metadata.Namespace + "/" + pod.Metadata.Name,
+		Timeout: d.timeout,
+		Labels: map[string]string{
+			namespaceLabel: pod.Metadata.Namespace,
+			podLabel:       pod.Metadata.Name,
+			containerLabel: container,
+		},
+	}
+
+	if target.Validate() != nil {
+		return config.Target{}, false
+	}
+
+	return target, true
+}
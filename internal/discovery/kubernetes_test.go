@@ -0,0 +1,237 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/70k10/beat-exporter/internal/config"
+)
+
+// testPod returns a pod resource of namespace monitoring, annotated with port unless it is 0
+func testPod(name string, resourceVersion string, podIP string, port int, containers ...string) map[string]interface{} {
+	annotations := map[string]string{}
+	if port != 0 {
+		annotations["beat-exporter/port"] = fmt.Sprint(port)
+	}
+
+	var specContainers []map[string]interface{}
+	for i, container := range containers {
+		specContainer := map[string]interface{}{"name": container}
+		// the first container exposes the annotated port
+		if i == 0 && len(containers) > 1 {
+			specContainer["ports"] = []map[string]int{{"containerPort": port}}
+		}
+		specContainers = append(specContainers, specContainer)
+	}
+
+	return map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":            name,
+			"namespace":       "monitoring",
+			"uid":             "uid-" + name,
+			"resourceVersion": resourceVersion,
+			"annotations":     annotations,
+		},
+		"spec":   map[string]interface{}{"containers": specContainers},
+		"status": map[string]string{"phase": "Running", "podIP": podIP},
+	}
+}
+
+func podTarget(name string, uri string, container string) config.Target {
+	return config.Target{
+		URI:     uri,
+		Label:   "monitoring/" + name,
+		Timeout: 5 * time.Second,
+		Labels:  map[string]string{"namespace": "monitoring", "pod": name, "container": container},
+	}
+}
+
+// fakeKubernetesAPI serves a pod list under prefix and streams the events sent on its events channel to watch requests
+type fakeKubernetesAPI struct {
+	t      *testing.T
+	prefix string
+	pods   []map[string]interface{}
+	events chan map[string]interface{}
+}
+
+func (f *fakeKubernetesAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != f.prefix+"/api/v1/namespaces/monitoring/pods" {
+		f.t.Errorf("unexpected path %s", r.URL.Path)
+		http.NotFound(w, r)
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer secret-token" {
+		f.t.Errorf("unexpected authorization header %q", r.Header.Get("Authorization"))
+	}
+	if r.URL.Query().Get("labelSelector") != "app=beats" {
+		f.t.Errorf("unexpected label selector %q", r.URL.Query().Get("labelSelector"))
+	}
+
+	if r.URL.Query().Get("watch") == "" {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"metadata": map[string]string{"resourceVersion": "10"},
+			"items":    f.pods,
+		})
+		return
+	}
+
+	if r.URL.Query().Get("resourceVersion") == "" {
+		f.t.Errorf("watch without resource version")
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.(http.Flusher).Flush()
+
+	encoder := json.NewEncoder(w)
+	for {
+		select {
+		case event := <-f.events:
+			encoder.Encode(event)
+			w.(http.Flusher).Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func TestKubernetesDiscovererListAndWatch(t *testing.T) {
+	api := &fakeKubernetesAPI{
+		t:      t,
+		prefix: "/k8s",
+		pods: []map[string]interface{}{
+			testPod("filebeat-1", "8", "10.0.0.1", 5066, "filebeat", "sidecar"),
+			testPod("nginx-1", "9", "10.0.0.9", 0, "nginx"),
+		},
+		events: make(chan map[string]interface{}),
+	}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	err := ioutil.WriteFile(tokenFile, []byte("secret-token\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewKubernetesDiscoverer(config.KubernetesSDConfig{
+		APIServer:       server.URL + "/k8s/",
+		Namespace:       "monitoring",
+		LabelSelector:   "app=beats",
+		BearerTokenFile: tokenFile,
+	}, 5*time.Second)
+
+	stopCh := make(chan struct{})
+	ch := make(chan []config.Target)
+	done := make(chan struct{})
+	go func() {
+		d.Run(stopCh, ch)
+		close(done)
+	}()
+	defer func() {
+		close(stopCh)
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Error("Run did not return after stop")
+		}
+	}()
+
+	expectTargets := func(step string, expected []config.Target) {
+		t.Helper()
+		select {
+		case targets := <-ch:
+			if !reflect.DeepEqual(targets, expected) {
+				t.Errorf("%s: unexpected targets\n got: %+v\nwant: %+v", step, targets, expected)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: no targets sent", step)
+		}
+	}
+	sendEvent := func(eventType string, object map[string]interface{}) {
+		t.Helper()
+		select {
+		case api.events <- map[string]interface{}{"type": eventType, "object": object}:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s event not consumed by the watch", eventType)
+		}
+	}
+
+	filebeat := podTarget("filebeat-1", "http://10.0.0.1:5066", "filebeat")
+	expectTargets("list", []config.Target{filebeat})
+
+	metricbeat := podTarget("metricbeat-1", "http://10.0.0.2:5067", "metricbeat")
+	sendEvent("ADDED", testPod("metricbeat-1", "11", "10.0.0.2", 5067, "metricbeat"))
+	expectTargets("added", []config.Target{filebeat, metricbeat})
+
+	// bookmarks and pods without annotation do not change the targets, the next update is the modification
+	sendEvent("BOOKMARK", map[string]interface{}{"metadata": map[string]string{"resourceVersion": "12"}})
+	sendEvent("ADDED", testPod("nginx-2", "13", "10.0.0.10", 0, "nginx"))
+
+	metricbeat = podTarget("metricbeat-1", "http://10.0.0.3:5067", "metricbeat")
+	sendEvent("MODIFIED", testPod("metricbeat-1", "14", "10.0.0.3", 5067, "metricbeat"))
+	expectTargets("modified", []config.Target{filebeat, metricbeat})
+
+	sendEvent("DELETED", testPod("filebeat-1", "15", "10.0.0.1", 5066, "filebeat", "sidecar"))
+	expectTargets("deleted", []config.Target{metricbeat})
+}
+
+func TestKubernetesDiscovererPodTarget(t *testing.T) {
+	d := &kubernetesDiscoverer{timeout: 5 * time.Second}
+
+	for _, test := range []struct {
+		name     string
+		pod      string
+		expected *config.Target
+	}{
+		{
+			name: "path and scheme annotations",
+			pod: `{"metadata": {"name": "filebeat-1", "namespace": "monitoring", "annotations": {
+			         "beat-exporter/port": "5066", "beat-exporter/path": "beat/", "beat-exporter/scheme": "https"}},
+			       "spec": {"containers": [{"name": "filebeat"}]},
+			       "status": {"phase": "Running", "podIP": "10.0.0.1"}}`,
+			expected: &config.Target{
+				URI:     "https://10.0.0.1:5066/beat",
+				Label:   "monitoring/filebeat-1",
+				Timeout: 5 * time.Second,
+				Labels:  map[string]string{"namespace": "monitoring", "pod": "filebeat-1", "container": "filebeat"},
+			},
+		},
+		{
+			name: "pending pod",
+			pod: `{"metadata": {"name": "filebeat-1", "namespace": "monitoring", "annotations": {"beat-exporter/port": "5066"}},
+			       "status": {"phase": "Pending"}}`,
+		},
+		{
+			name: "invalid port",
+			pod: `{"metadata": {"name": "filebeat-1", "namespace": "monitoring", "annotations": {"beat-exporter/port": "http"}},
+			       "status": {"phase": "Running", "podIP": "10.0.0.1"}}`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			pod := kubernetesPod{}
+			err := json.Unmarshal([]byte(test.pod), &pod)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			target, ok := d.podTarget(pod)
+			if test.expected == nil {
+				if ok {
+					t.Errorf("expected no target, got %+v", target)
+				}
+				return
+			}
+
+			if !ok || !reflect.DeepEqual(target, *test.expected) {
+				t.Errorf("unexpected target\n got: %+v (%v)\nwant: %+v", target, ok, *test.expected)
+			}
+		})
+	}
+}
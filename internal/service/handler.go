@@ -10,14 +10,22 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-// SetupServiceListener setup signal handler
-func SetupServiceListener(stopCh chan<- bool, serviceName string, logger log.StdLogger) error {
+// SetupServiceListener setup signal handler, SIGHUP requests a reload instead of stopping
+func SetupServiceListener(stopCh chan<- bool, reloadCh chan<- bool, serviceName string, logger log.StdLogger) error {
 	go func() {
 		sigs := make(chan os.Signal, 1)
 		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGKILL, syscall.SIGHUP)
-		logger.Printf("Signal received: %v", <-sigs)
-		stopCh <- true
-		close(stopCh)
+		for {
+			sig := <-sigs
+			logger.Printf("Signal received: %v", sig)
+			if sig == syscall.SIGHUP {
+				reloadCh <- true
+				continue
+			}
+			stopCh <- true
+			close(stopCh)
+			return
+		}
 	}()
 
 	return nil
@@ -9,11 +9,12 @@ import (
 )
 
 type beatExporterService struct {
-	stopCh chan<- bool
+	stopCh   chan<- bool
+	reloadCh chan<- bool
 }
 
 func (s *beatExporterService) Execute(args []string, r <-chan svc.ChangeRequest, changes chan<- svc.Status) (ssec bool, errno uint32) {
-	const cmdsAccepted = svc.AcceptStop | svc.AcceptShutdown
+	const cmdsAccepted = svc.AcceptStop | svc.AcceptShutdown | svc.AcceptParamChange
 	changes <- svc.Status{State: svc.StartPending}
 	changes <- svc.Status{State: svc.Running, Accepts: cmdsAccepted}
 loop:
@@ -23,6 +24,8 @@ loop:
 			switch c.Cmd {
 			case svc.Interrogate:
 				changes <- c.CurrentStatus
+			case svc.ParamChange:
+				s.reloadCh <- true
 			case svc.Stop, svc.Shutdown:
 				s.stopCh <- true
 				break loop
@@ -35,8 +38,8 @@ loop:
 	return
 }
 
-// SetupServiceListener setups service handler for windows
-func SetupServiceListener(stopCh chan<- bool, serviceName string, logger log.StdLogger) error {
+// SetupServiceListener setups service handler for windows, a paramchange control requests a reload
+func SetupServiceListener(stopCh chan<- bool, reloadCh chan<- bool, serviceName string, logger log.StdLogger) error {
 	isInteractive, err := svc.IsAnInteractiveSession()
 	if err != nil {
 		return err
@@ -44,7 +47,7 @@ func SetupServiceListener(stopCh chan<- bool, serviceName string, logger log.Std
 
 	if !isInteractive {
 		go func() {
-			err = svc.Run(serviceName, &beatExporterService{stopCh: stopCh})
+			err = svc.Run(serviceName, &beatExporterService{stopCh: stopCh, reloadCh: reloadCh})
 			if err != nil {
 				logger.Printf("Failed to start service: %v", err)
 			}
@@ -2,15 +2,13 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"flag"
 	"fmt"
-	"io/ioutil"
-	"net"
 	"net/http"
-	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -19,11 +17,16 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/prometheus/common/version"
 	"github.com/70k10/beat-exporter/collector"
+	"github.com/70k10/beat-exporter/internal/config"
+	"github.com/70k10/beat-exporter/internal/discovery"
 	"github.com/70k10/beat-exporter/internal/service"
 )
 
 const (
 	serviceName = "beat_exporter"
+
+	// staticTargetSource identifies targets from -config.file and -beat.uri in the target manager
+	staticTargetSource = "static"
 )
 
 func main() {
@@ -36,7 +39,19 @@ func main() {
 		beatURI       = flag.String("beat.uri", "http://localhost:5066", "HTTP API address of beat.\n" +
 			"Comma-separated for multiple URIs. Ex. \"http://localhost:5066,http://localhost:5067\"\n" +
 			"Append semi-colon to URI followed by a name to modify the collector label. Ex. \"http://localhost:5066;servicefilebeat\"\n")
-		beatTimeout   = flag.Duration("beat.timeout", 10*time.Second, "Timeout for trying to get stats from beat.")
+		beatTimeout   = flag.Duration("beat.timeout", config.DefaultTimeout, "Timeout for trying to get stats from beat.")
+		configFile    = flag.String("config.file", "", "YAML file listing the beat targets. Targets from -beat.uri are added when it is set explicitly.")
+		adminTokenFile = flag.String("web.admin-token-file", "", "File with the bearer token of the target admin API under /api/v1/targets, the API is disabled when empty.")
+		enableLifecycle = flag.Bool("web.enable-lifecycle", false, "Enable reloading the target configuration with a POST to /-/reload.")
+		mappings      = mappingFlags{
+			schema:       flag.String("metrics.schema", "v1", "Metric schema of the built-in mappings, v1 or v2 with Prometheus types, units and names."),
+			dir:          flag.String("metrics.mapping-dir", "", "Directory of *.yml metric mapping files, a file replaces the built-in mapping with the same name."),
+			flatten:      flag.Bool("metrics.flatten", false, "Also export every numeric value of /stats that no mapping exports, named after its path."),
+			flattenAllow: flag.String("metrics.flatten-allow", "", "Comma-separated path globs of the values exported by -metrics.flatten, e.g. \"libbeat.**,beat.cgroup.*.*\". All values when empty."),
+			flattenDeny:  flag.String("metrics.flatten-deny", "", "Comma-separated path globs of the values left out by -metrics.flatten."),
+			prefix:       flag.String("metrics.prefix", "", "Fixed prefix of the beat metrics, e.g. beat, instead of the beat type, which is then set as the beat label."),
+		}
+		timeoutOffset = flag.Duration("web.scrape-timeout-offset", 500*time.Millisecond, "Offset subtracted from the X-Prometheus-Scrape-Timeout-Seconds header of a scrape, the rest bounds the requests to the beats.")
 		showVersion   = flag.Bool("version", false, "Show version and exit")
 	)
 	flag.Parse()
@@ -54,9 +69,24 @@ func main() {
 		},
 	})
 
+	switch flag.Arg(0) {
+	case "":
+	case "check-config":
+		os.Exit(runCheckConfig(flag.Args()[1:], *configFile, *beatURI, *beatTimeout, mappings))
+	case "scrape":
+		os.Exit(runScrape(flag.Args()[1:], Name, *configFile, *beatURI, *beatTimeout, mappings))
+	default:
+		log.Fatalf("Unknown command %q, expected check-config or scrape", flag.Arg(0))
+	}
+
+	if *timeoutOffset < 0 {
+		log.Fatalf("Invalid -web.scrape-timeout-offset %s, it must not be negative", *timeoutOffset)
+	}
+
 	stopCh := make(chan bool)
+	reloadCh := make(chan bool)
 
-	err := service.SetupServiceListener(stopCh, serviceName, log.StandardLogger())
+	err := service.SetupServiceListener(stopCh, reloadCh, serviceName, log.StandardLogger())
 	if err != nil {
 		log.WithFields(log.Fields{
 			"err": err,
@@ -67,79 +97,82 @@ func main() {
 	registry := prometheus.NewRegistry()
 	versionMetric := version.NewCollector(Name)
 	registry.MustRegister(versionMetric)
-	var collectorLabel string
 
-	for _, URI := range strings.Split(*beatURI,",") {
-		if len(URI) > 0 {
-			URI, collectorLabel = parseCollectorLabel(URI)
-			parsedURL, err := url.Parse(URI)
-			if err != nil {
-				log.Fatalf("Failed to parse beat.uri, error: %v", err)
-			}
-			httpClient := &http.Client{
-				Timeout: *beatTimeout,
-			}
+	cfg, err := loadConfig(*configFile, *beatURI, *beatTimeout)
+	if err != nil {
+		log.Fatalf("Failed to load targets, error: %v", err)
+	}
 
-			if parsedURL.Scheme == "unix" {
-				unixPath := parsedURL.Path
-				parsedURL.Scheme = "http"
-				parsedURL.Host = "localhost"
-				parsedURL.Path = ""
-				httpClient.Transport = &http.Transport{
-					DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
-						return (&net.Dialer{}).DialContext(ctx, "unix", unixPath)
-					},
-				}
-			}
+	metricMappings, err := mappings.load()
+	if err != nil {
+		log.Fatalf("Failed to load metric mappings, error: %v", err)
+	}
 
-			log.WithFields(log.Fields{"URI": URI}).Info("Validating Beat URI accessible.")
+	err = cfg.CheckLabels(metricMappings.LabelNames())
+	if err != nil {
+		log.Fatalf("Failed to load targets, error: %v", err)
+	}
 
-			t := time.NewTicker(1 * time.Second)
+	manager := newTargetManager(Name, metricMappings)
+	manager.SetConfig(cfg)
+	err = manager.Sync(staticTargetSource, cfg.Targets)
+	if err != nil {
+		log.Fatalf("Failed to add targets, error: %v", err)
+	}
 
-		    beatValidation:
-			for {
-				select {
-				case <-t.C:
-					err = testBeatStats(httpClient, *parsedURL)
-					if err != nil {
-						log.Errorf("Failed to connect to Beat, with error: %v, retrying in 1s", err)
-						continue
-					}
+	// discovered targets that cannot be added are logged by the manager, the others are scraped
+	discoveryManager := discovery.NewManager(func(source string, targets []config.Target) {
+		manager.Sync(source, targets)
+	})
+	discoveryManager.ApplyConfig(cfg.Discovery, cfg.Global.Timeout)
 
-					break beatValidation
+	prober := newProbeHandler(Name, metricMappings)
+	prober.SetConfig(cfg)
 
-				case <-stopCh:
-					os.Exit(0) // signal received, stop gracefully
-				}
-			}
+	if *adminTokenFile != "" {
+		_, err = readAdminToken(*adminTokenFile)
+		if err != nil {
+			log.Fatalf("Failed to read admin token file, error: %v", err)
+		}
+	}
 
-			t.Stop()
+	admin := newAdminAPI(manager, *adminTokenFile)
+	admin.SetConfig(cfg)
 
-			parsedCollectorLabel, beatInfo, beatCollector := collector.NewMainCollector(httpClient, parsedURL, Name, collectorLabel)
-			registry.MustRegister(beatCollector)
+	// reloads come from both SIGHUP and /-/reload, apply one config at a time
+	var reloadMu sync.Mutex
+	reloadTargets := func() error {
+		reloadMu.Lock()
+		defer reloadMu.Unlock()
 
-			log.WithFields(
-				log.Fields{
-					"beat":     beatInfo.Beat,
-					"version":  beatInfo.Version,
-					"name":     beatInfo.Name,
-					"hostname": beatInfo.Hostname,
-					"uuid":     beatInfo.UUID,
-				}).Infof("%s: Target beat configuration loaded successfully!", parsedCollectorLabel)
+		cfg, err := loadConfig(*configFile, *beatURI, *beatTimeout)
+		if err != nil {
+			return err
 		}
+
+		err = cfg.CheckLabels(metricMappings.LabelNames())
+		if err != nil {
+			return err
+		}
+
+		manager.SetConfig(cfg)
+		syncErr := manager.Sync(staticTargetSource, cfg.Targets)
+		discoveryManager.ApplyConfig(cfg.Discovery, cfg.Global.Timeout)
+		prober.SetConfig(cfg)
+		admin.SetConfig(cfg)
+		return syncErr
 	}
 
-	http.Handle(*metricsPath, promhttp.HandlerFor(
-		registry,
-		promhttp.HandlerOpts{
-			ErrorLog:           log.New(),
-			DisableCompression: false,
-			ErrorHandling:      promhttp.ContinueOnError}),
-	)
+	http.Handle(*metricsPath, MetricsHandler(registry, manager, *timeoutOffset))
 
+	http.Handle("/probe", prober)
+	if *adminTokenFile != "" {
+		http.HandleFunc("/api/v1/targets", admin.TargetsHandler)
+	}
+	http.HandleFunc("/-/reload", ReloadHandler(reloadTargets, *enableLifecycle))
+	http.HandleFunc("/debug/schema", SchemaHandler(manager))
 	http.HandleFunc("/", IndexHandler(*metricsPath))
 
-
 	go func() {
 		defer func() {
 			stopCh <- true
@@ -170,13 +203,58 @@ func main() {
 	}()
 
 	for {
-		if <-stopCh {
-			log.Info("Shutting down beats exporter")
-			break
+		select {
+		case <-reloadCh:
+			log.Info("Reloading target configuration")
+			err := reloadTargets()
+			if err != nil {
+				log.WithFields(log.Fields{
+					"err": err,
+				}).Errorf("Failed to reload target configuration: %v", err)
+			}
+		case stop := <-stopCh:
+			if stop {
+				log.Info("Shutting down beats exporter")
+				return
+			}
 		}
 	}
 }
 
+// MetricsHandler returns a http handler serving the metrics of the registry and of the targets of manager.
+// Requests to the beats end with the scrape timeout of Prometheus minus timeoutOffset, or half of it when the offset
+// is larger, so a slow beat only misses its own metrics.
+func MetricsHandler(registry prometheus.Gatherer, manager *targetManager, timeoutOffset time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if header := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"); header != "" {
+			seconds, err := strconv.ParseFloat(header, 64)
+			if err != nil {
+				log.WithFields(log.Fields{"err": err}).Errorf("Invalid X-Prometheus-Scrape-Timeout-Seconds header %q", header)
+			} else if seconds > 0 {
+				// the offset takes at most half of short scrape timeouts, the beats keep the other half
+				scrapeTimeout := time.Duration(seconds * float64(time.Second))
+				timeout := scrapeTimeout - timeoutOffset
+				if timeout < scrapeTimeout/2 {
+					timeout = scrapeTimeout / 2
+				}
+
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+		}
+
+		promhttp.HandlerFor(
+			prometheus.Gatherers{registry, manager.Gatherer(ctx)},
+			promhttp.HandlerOpts{
+				ErrorLog:           log.New(),
+				DisableCompression: false,
+				ErrorHandling:      promhttp.ContinueOnError}).ServeHTTP(w, r)
+	}
+}
+
 // IndexHandler returns a http handler with the correct metricsPath
 func IndexHandler(metricsPath string) http.HandlerFunc {
 
@@ -190,6 +268,12 @@ func IndexHandler(metricsPath string) http.HandlerFunc {
 		<p>
 			<a href='%s'>Metrics</a>
 		</p>
+		<p>
+			<a href='/probe?target=http://localhost:5066'>Probe http://localhost:5066</a>
+		</p>
+		<p>
+			<a href='/debug/schema'>Schema reports</a>
+		</p>
 	</body>
 </html>
 `
@@ -200,38 +284,173 @@ func IndexHandler(metricsPath string) http.HandlerFunc {
 	}
 }
 
-func parseCollectorLabel(URI string) (string,string) {
-	splitURIandLabel := strings.Split(URI,";")
-	if len(splitURIandLabel) > 1 && len(splitURIandLabel[1]) > 0 {
-		return splitURIandLabel[0], splitURIandLabel[1]
+// SchemaHandler returns a http handler listing the unmapped and missing stats paths of the target passed in the
+// target parameter, or the number of them for every target without it
+func SchemaHandler(manager *targetManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+		collectorLabel := r.URL.Query().Get("target")
+		if collectorLabel == "" {
+			for _, target := range manager.Targets() {
+				report := target.SchemaReport
+				if report.Time.IsZero() {
+					fmt.Fprintf(w, "%s: no stats yet\n", target.CollectorLabel)
+					continue
+				}
+				fmt.Fprintf(w, "%s: %d unmapped, %d missing paths\n", target.CollectorLabel, len(report.Unmapped), len(report.Missing))
+			}
+			return
+		}
+
+		beatCollector, ok := manager.Collector(collectorLabel)
+		if !ok {
+			http.Error(w, fmt.Sprintf("unknown target %q", collectorLabel), http.StatusNotFound)
+			return
+		}
+
+		beatInfo := beatCollector.GetCollectorInfo()
+		report := beatCollector.GetSchemaReport()
+		if report.Time.IsZero() {
+			fmt.Fprintf(w, "%s (%s %s): no stats yet\n", collectorLabel, beatInfo.Beat, beatInfo.Version)
+			return
+		}
+
+		fmt.Fprintf(w, "%s (%s %s), checked %s\n", collectorLabel, beatInfo.Beat, beatInfo.Version, report.Time.Format(time.RFC3339))
+		for _, section := range []struct {
+			title string
+			paths []string
+		}{
+			{"Unmapped paths, no metric mapping covers them", report.Unmapped},
+			{"Missing paths, the metric mappings expect them", report.Missing},
+		} {
+			fmt.Fprintf(w, "\n%s (%d):\n", section.title, len(section.paths))
+			for _, path := range section.paths {
+				fmt.Fprintf(w, "  %s\n", path)
+			}
+		}
+	}
+}
+
+// ReloadHandler returns a http handler re-reading the target configuration on POST, requests are forbidden
+// unless enabled
+func ReloadHandler(reload func() error, enabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !enabled {
+			http.Error(w, "Lifecycle API is not enabled, start with -web.enable-lifecycle", http.StatusForbidden)
+			return
+		}
+
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Only POST requests allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		log.Info("Reloading target configuration")
+		err := reload()
+		if err != nil {
+			log.WithFields(log.Fields{
+				"err": err,
+			}).Errorf("Failed to reload target configuration: %v", err)
+			http.Error(w, fmt.Sprintf("failed to reload config: %s", err), http.StatusInternalServerError)
+		}
 	}
-	return splitURIandLabel[0], ""
 }
 
-func testBeatStats(client *http.Client, url url.URL) error {
-	beatInfo := &collector.BeatInfo{}
+// mappingFlags flags of the metric mappings
+type mappingFlags struct {
+	schema       *string
+	dir          *string
+	flatten      *bool
+	flattenAllow *string
+	flattenDeny  *string
+	prefix       *string
+}
 
-	response, err := client.Get(url.String())
+// load returns the built-in metric mappings of the schema with the mapping files, prefix and flattening of the flags
+func (f mappingFlags) load() (*collector.Mappings, error) {
+	mappings, err := collector.LoadMappings(*f.schema, *f.dir)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer response.Body.Close()
 
-	if response.StatusCode != http.StatusOK {
-		log.Errorf("Beat URL: %q status code: %d", url.String(), response.StatusCode)
-		return err
+	if *f.prefix != "" {
+		err = mappings.SetPrefix(*f.prefix)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if *f.flatten {
+		err = mappings.SetFlattening(collector.Flattening{
+			Allow: splitList(*f.flattenAllow),
+			Deny:  splitList(*f.flattenDeny),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return mappings, nil
+}
+
+// splitList splits a comma-separated flag value, ignoring empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
 	}
 
-	bodyBytes, err := ioutil.ReadAll(response.Body)
+	return items
+}
+
+// loadConfig returns the configuration file merged with the targets of the -beat.uri flag
+func loadConfig(configFile string, beatURI string, beatTimeout time.Duration) (*config.Config, error) {
+	if configFile == "" {
+		targets, err := uriFlagTargets(beatURI, beatTimeout)
+		if err != nil {
+			return nil, err
+		}
+
+		return &config.Config{
+			Global:  config.GlobalConfig{Timeout: beatTimeout},
+			Targets: targets,
+		}, nil
+	}
+
+	cfg, err := config.Load(configFile, beatTimeout)
 	if err != nil {
-		log.Error("Can't read body of response")
-		return err
+		return nil, err
 	}
 
-	err = json.Unmarshal(bodyBytes, &beatInfo)
+	// -beat.uri always has a default value, only use it when passed explicitly
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "beat.uri" {
+			var targets []config.Target
+			targets, err = uriFlagTargets(beatURI, beatTimeout)
+			cfg.Targets = append(cfg.Targets, targets...)
+		}
+	})
 	if err != nil {
-		log.Error("Could not parse JSON response for target")
-		return err
+		return nil, err
 	}
-	return nil
+
+	return cfg, nil
+}
+
+// uriFlagTargets builds the targets of -beat.uri, config files are validated when loaded
+func uriFlagTargets(beatURI string, beatTimeout time.Duration) ([]config.Target, error) {
+	targets := config.FromURIFlag(beatURI, beatTimeout)
+	for _, target := range targets {
+		err := target.Validate()
+		if err != nil {
+			return nil, fmt.Errorf("beat.uri: %v", err)
+		}
+	}
+
+	return targets, nil
 }
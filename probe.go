@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/70k10/beat-exporter/collector"
+	"github.com/70k10/beat-exporter/internal/config"
+)
+
+// probeHandler scrapes the beat passed in the target parameter with a short-lived collector,
+// the way blackbox_exporter probes its targets
+type probeHandler struct {
+	mtx            sync.RWMutex
+	name           string
+	mappings       *collector.Mappings
+	modules        map[string]config.Module
+	defaultTimeout time.Duration
+}
+
+func newProbeHandler(name string, mappings *collector.Mappings) *probeHandler {
+	return &probeHandler{
+		name:           name,
+		mappings:       mappings,
+		modules:        map[string]config.Module{},
+		defaultTimeout: config.DefaultTimeout,
+	}
+}
+
+// SetConfig replaces the modules available to probes
+func (h *probeHandler) SetConfig(cfg *config.Config) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	h.modules = cfg.Modules
+	h.defaultTimeout = cfg.Global.Timeout
+}
+
+func (h *probeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	target, err := h.probeTarget(params.Get("target"), params.Get("module"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	httpClient, parsedURL, err := newTargetClient(target)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	_, beatCollector := collector.NewMainCollector(httpClient, parsedURL, h.name, "", nil, collector.LabelTemplates{}, collector.Polling{}, h.mappings)
+	defer beatCollector.Stop()
+
+	// wait for the beat type, an unreachable beat is reported as down
+	select {
+	case <-beatCollector.Detected():
+	case <-time.After(target.Timeout):
+		log.WithFields(log.Fields{"URI": target.URI}).Error("Probe timed out detecting beat type")
+	case <-r.Context().Done():
+		return
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(beatCollector)
+
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{
+		ErrorLog:      log.New(),
+		ErrorHandling: promhttp.ContinueOnError,
+	}).ServeHTTP(w, r)
+}
+
+// probeTarget builds the target for the target and module parameters of a probe
+func (h *probeHandler) probeTarget(URI string, moduleName string) (config.Target, error) {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+
+	if URI == "" {
+		return config.Target{}, fmt.Errorf("target parameter is missing")
+	}
+
+	// host:port like blackbox_exporter
+	if !strings.Contains(URI, "://") {
+		URI = "http://" + URI
+	}
+
+	target := config.Target{URI: URI, Timeout: h.defaultTimeout}
+
+	if moduleName != "" {
+		module, ok := h.modules[moduleName]
+		if !ok {
+			return config.Target{}, fmt.Errorf("unknown module %q", moduleName)
+		}
+
+		// the credentials of the module go to whatever host the caller passes, only to the allowed ones and
+		// never in plain text
+		targetURL, err := url.Parse(URI)
+		if err != nil {
+			return config.Target{}, fmt.Errorf("invalid target %q: %v", URI, err)
+		}
+		if !module.Allows(targetURL) {
+			return config.Target{}, fmt.Errorf("target %q is not allowed by module %q", URI, moduleName)
+		}
+		if module.SendsCredentials() && targetURL.Scheme == "http" {
+			return config.Target{}, fmt.Errorf("module %q sends credentials, target %q must use https", moduleName, URI)
+		}
+
+		target.Timeout = module.Timeout
+		target.Auth = module.Auth
+		target.TLS = module.TLS
+	}
+
+	err := target.Validate()
+	if err != nil {
+		return config.Target{}, err
+	}
+
+	return target, nil
+}
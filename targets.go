@@ -0,0 +1,377 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/70k10/beat-exporter/collector"
+	"github.com/70k10/beat-exporter/internal/config"
+)
+
+var (
+	errTargetExists = errors.New("target already exists")
+	errLabelInUse   = errors.New("collector label conflict")
+)
+
+// targetManager keeps the target collectors in sync with the configured targets. Collectors are registered in
+// its own registry, which rejects conflicting targets, and gathered through Gatherer.
+type targetManager struct {
+	mtx      sync.Mutex
+	registry *prometheus.Registry
+	name     string
+	defaults config.GlobalConfig
+	mappings *collector.Mappings
+	sources  map[string]map[string]*managedTarget
+	added    uint64
+}
+
+type managedTarget struct {
+	// source is the target as provided, target has the global defaults applied
+	source    config.Target
+	target    config.Target
+	collector collector.TargetCollector
+	// seq orders the targets by creation, duplicate is set while an older target has the same collector label
+	seq       uint64
+	duplicate bool
+}
+
+// targetStatus a target with its detected beat, last scrape and schema report. Exported is false while an older
+// target has the same collector label.
+type targetStatus struct {
+	Source         string
+	Target         config.Target
+	CollectorLabel string
+	Exported       bool
+	BeatInfo       collector.BeatInfo
+	ScrapeStatus   collector.ScrapeStatus
+	SchemaReport   collector.SchemaReport
+}
+
+// contextCollector collects a target with the context of a scrape
+type contextCollector struct {
+	ctx       context.Context
+	collector collector.TargetCollector
+}
+
+func newTargetManager(name string, mappings *collector.Mappings) *targetManager {
+	return &targetManager{
+		registry: prometheus.NewRegistry(),
+		name:     name,
+		mappings: mappings,
+		sources:  make(map[string]map[string]*managedTarget),
+	}
+}
+
+// SetConfig sets the global defaults of targets, targets of all sources are synced again when they change
+func (m *targetManager) SetConfig(cfg *config.Config) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if reflect.DeepEqual(m.defaults, cfg.Global) {
+		return
+	}
+	m.defaults = cfg.Global
+
+	for source, managedTargets := range m.sources {
+		var targets []config.Target
+		for _, managed := range managedTargets {
+			targets = append(targets, managed.source)
+		}
+		m.sync(source, targets)
+	}
+}
+
+// Sync replaces the targets provided by source. Targets that did not change keep their collector,
+// removed targets are unregistered and new targets are registered. Targets that cannot be added are logged and
+// skipped, the error reports the first of them.
+func (m *targetManager) Sync(source string, targets []config.Target) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	return m.sync(source, targets)
+}
+
+func (m *targetManager) sync(source string, targets []config.Target) error {
+	current := m.sources[source]
+	if current == nil {
+		current = make(map[string]*managedTarget)
+	}
+
+	wanted := make(map[string]config.Target, len(targets))
+	for _, target := range targets {
+		wanted[targetKey(target.WithDefaults(m.defaults))] = target
+	}
+
+	for key, managed := range current {
+		if _, ok := wanted[key]; ok {
+			continue
+		}
+
+		m.registry.Unregister(managed.collector)
+		managed.collector.Stop()
+		delete(current, key)
+
+		log.WithFields(log.Fields{"URI": managed.target.URI, "source": source}).
+			Infof("%s: Target removed", managed.collector.GetCollectorLabel())
+	}
+
+	// the labels of removed targets are free now, the new targets also check the labels of each other
+	m.sources[source] = current
+
+	var failed int
+	var firstErr error
+	for key, target := range wanted {
+		if _, ok := current[key]; ok {
+			continue
+		}
+
+		managed, err := m.newManagedTarget(target)
+		if err != nil {
+			log.WithFields(log.Fields{"URI": target.URI, "source": source, "err": err}).
+				Errorf("Failed to add target: %v", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			failed++
+			continue
+		}
+
+		current[key] = managed
+	}
+
+	if len(current) == 0 {
+		delete(m.sources, source)
+	}
+
+	if firstErr != nil {
+		return fmt.Errorf("%d of %d targets not added, first error: %w", failed, len(wanted), firstErr)
+	}
+	return nil
+}
+
+// Add registers a single target of source, unlike Sync it reports why the target could not be added
+func (m *targetManager) Add(source string, target config.Target) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	key := targetKey(target.WithDefaults(m.defaults))
+	if _, ok := m.sources[source][key]; ok {
+		return fmt.Errorf("%w: %s", errTargetExists, target.URI)
+	}
+
+	managed, err := m.newManagedTarget(target)
+	if err != nil {
+		return err
+	}
+
+	if m.sources[source] == nil {
+		m.sources[source] = make(map[string]*managedTarget)
+	}
+	m.sources[source][key] = managed
+
+	return nil
+}
+
+// Remove unregisters the target of source with the collector label, it returns false when there is none
+func (m *targetManager) Remove(source string, collectorLabel string) bool {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	for key, managed := range m.sources[source] {
+		if managed.collector.GetCollectorLabel() != collectorLabel {
+			continue
+		}
+
+		m.registry.Unregister(managed.collector)
+		managed.collector.Stop()
+		delete(m.sources[source], key)
+
+		log.WithFields(log.Fields{"URI": managed.target.URI, "source": source}).
+			Infof("%s: Target removed", collectorLabel)
+
+		return true
+	}
+
+	return false
+}
+
+// Targets returns the targets of all sources ordered by source and collector label
+func (m *targetManager) Targets() []targetStatus {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	// updates the duplicate flags from the current collector labels
+	m.exportedTargets()
+
+	var targets []targetStatus
+	for source, managedTargets := range m.sources {
+		for _, managed := range managedTargets {
+			targets = append(targets, targetStatus{
+				Source:         source,
+				Target:         managed.target,
+				CollectorLabel: managed.collector.GetCollectorLabel(),
+				Exported:       !managed.duplicate,
+				BeatInfo:       managed.collector.GetCollectorInfo(),
+				ScrapeStatus:   managed.collector.GetScrapeStatus(),
+				SchemaReport:   managed.collector.GetSchemaReport(),
+			})
+		}
+	}
+
+	sort.Slice(targets, func(i, j int) bool {
+		if targets[i].Source != targets[j].Source {
+			return targets[i].Source < targets[j].Source
+		}
+		return targets[i].CollectorLabel < targets[j].CollectorLabel
+	})
+
+	return targets
+}
+
+// Collector returns the collector of the target with collectorLabel, whatever its source
+func (m *targetManager) Collector(collectorLabel string) (collector.TargetCollector, bool) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	for _, managedTargets := range m.sources {
+		for _, managed := range managedTargets {
+			if managed.collector.GetCollectorLabel() == collectorLabel {
+				return managed.collector, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// Gatherer returns a gatherer collecting all targets with ctx, which bounds the requests to the beats
+func (m *targetManager) Gatherer(ctx context.Context) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		registry := prometheus.NewRegistry()
+
+		m.mtx.Lock()
+		for _, managed := range m.exportedTargets() {
+			// cannot conflict, the collectors are registered in the registry of the manager
+			registry.MustRegister(contextCollector{ctx: ctx, collector: managed.collector})
+		}
+		m.mtx.Unlock()
+
+		return registry.Gather()
+	})
+}
+
+// exportedTargets returns the targets without the ones whose collector label, rendered from the label templates
+// once the beat is detected, is already used by an older target. Their metrics would be duplicates of the older
+// target's and fail the whole scrape.
+func (m *targetManager) exportedTargets() []*managedTarget {
+	var targets []*managedTarget
+	for _, managedTargets := range m.sources {
+		for _, managed := range managedTargets {
+			targets = append(targets, managed)
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].seq < targets[j].seq })
+
+	exported := targets[:0]
+	owners := make(map[string]*managedTarget, len(targets))
+	for _, managed := range targets {
+		collectorLabel := managed.collector.GetCollectorLabel()
+		owner, ok := owners[collectorLabel]
+		if !ok {
+			owners[collectorLabel] = managed
+			managed.duplicate = false
+			exported = append(exported, managed)
+			continue
+		}
+
+		if !managed.duplicate {
+			log.WithFields(log.Fields{"URI": managed.target.URI, "owner": owner.target.URI}).
+				Errorf("%s: Collector label is already used by target %s, the target is not exported", collectorLabel, owner.target.URI)
+		}
+		managed.duplicate = true
+	}
+
+	return exported
+}
+
+func (m *targetManager) newManagedTarget(source config.Target) (*managedTarget, error) {
+	target := source.WithDefaults(m.defaults)
+
+	// discovered targets are not checked with the configuration, a label of the mappings would replace theirs
+	err := target.CheckLabels(m.mappings.LabelNames())
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient, parsedURL, err := newTargetClient(target)
+	if err != nil {
+		return nil, err
+	}
+
+	templates, err := collector.NewLabelTemplates(target.CollectorTemplate, target.TemplateLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	polling := collector.Polling{Interval: target.PollInterval, MaxAge: target.MaxAge}
+	collectorLabel, beatCollector := collector.NewMainCollector(httpClient, parsedURL, m.name, target.Label, target.Labels, templates, polling, m.mappings)
+
+	// rendered labels only conflict once the beats are detected, the older target is exported then
+	owner := m.labelOwner(collectorLabel)
+	if owner != nil {
+		beatCollector.Stop()
+		return nil, fmt.Errorf("%w: %s is used by target %s", errLabelInUse, collectorLabel, owner.target.URI)
+	}
+
+	err = m.registry.Register(beatCollector)
+	if err != nil {
+		beatCollector.Stop()
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{"URI": target.URI}).Infof("%s: Target added", collectorLabel)
+
+	m.added++
+	return &managedTarget{
+		source:    source,
+		target:    target,
+		collector: beatCollector,
+		seq:       m.added,
+	}, nil
+}
+
+// labelOwner returns the target of any source with collectorLabel, nil when it is free
+func (m *targetManager) labelOwner(collectorLabel string) *managedTarget {
+	for _, managedTargets := range m.sources {
+		for _, managed := range managedTargets {
+			if managed.collector.GetCollectorLabel() == collectorLabel {
+				return managed
+			}
+		}
+	}
+
+	return nil
+}
+
+// targetKey identifies a target by its full configuration, so any change to it recreates the collector
+func targetKey(target config.Target) string {
+	key, _ := json.Marshal(target)
+	return string(key)
+}
+
+func (c contextCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.collector.Describe(ch)
+}
+
+func (c contextCollector) Collect(ch chan<- prometheus.Metric) {
+	c.collector.CollectContext(c.ctx, ch)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/70k10/beat-exporter/internal/config"
+)
+
+// newTLSConfig builds the client TLS configuration of a target on host. The CA and client certificate are loaded
+// once here to report errors early and reloaded on later handshakes when their files change.
+func newTLSConfig(cfg config.TLSConfig, host string) (*tls.Config, error) {
+	files := &tlsFiles{cfg: cfg, serverName: cfg.ServerName}
+	if files.serverName == "" {
+		files.serverName = host
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAFile != "" {
+		_, err := files.rootCAs()
+		if err != nil {
+			return nil, err
+		}
+
+		// the server certificate is verified against the current CA in VerifyConnection instead
+		tlsConfig.InsecureSkipVerify = true
+		if !cfg.InsecureSkipVerify {
+			tlsConfig.VerifyConnection = files.verifyConnection
+		}
+	}
+
+	if cfg.CertFile != "" {
+		_, err := files.clientCertificate(nil)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig.GetClientCertificate = files.clientCertificate
+	}
+
+	return tlsConfig, nil
+}
+
+// tlsFiles caches the CA pool and client certificate of a target
+type tlsFiles struct {
+	cfg        config.TLSConfig
+	serverName string
+
+	mtx       sync.Mutex
+	caStamp   string
+	pool      *x509.CertPool
+	certStamp string
+	cert      *tls.Certificate
+}
+
+func (f *tlsFiles) rootCAs() (*x509.CertPool, error) {
+	stamp, err := fileStamp(f.cfg.CAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	if stamp == f.caStamp {
+		return f.pool, nil
+	}
+
+	caCert, err := ioutil.ReadFile(f.cfg.CAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caCert) {
+		return nil, fmt.Errorf("no certificates found in %s", f.cfg.CAFile)
+	}
+
+	f.pool = pool
+	f.caStamp = stamp
+
+	return f.pool, nil
+}
+
+func (f *tlsFiles) clientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	stamp, err := fileStamp(f.cfg.CertFile, f.cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	if stamp == f.certStamp {
+		return f.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(f.cfg.CertFile, f.cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	f.cert = &cert
+	f.certStamp = stamp
+
+	return f.cert, nil
+}
+
+// verifyConnection verifies the server certificate chain and name like crypto/tls does, with the current CA pool.
+// The name is not taken from the connection state, which has no server name for IP addresses.
+func (f *tlsFiles) verifyConnection(state tls.ConnectionState) error {
+	pool, err := f.rootCAs()
+	if err != nil {
+		return err
+	}
+
+	if len(state.PeerCertificates) == 0 {
+		return fmt.Errorf("server sent no certificate")
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         pool,
+		DNSName:       f.serverName,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range state.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+
+	_, err = state.PeerCertificates[0].Verify(opts)
+	return err
+}
+
+// fileStamp identifies the current version of files by their modification time and size
+func fileStamp(paths ...string) (string, error) {
+	stamp := ""
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", err
+		}
+		stamp += fmt.Sprintf("%s:%d:%d;", path, info.ModTime().UnixNano(), info.Size())
+	}
+
+	return stamp, nil
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/70k10/beat-exporter/internal/config"
+)
+
+// testCert certificate with its key, signed by the parent or self-signed
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+var testSerial int64
+
+func newTestCert(t *testing.T, commonName string, parent *testCert, isCA bool, ips ...net.IP) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testSerial++
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(testSerial),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		IPAddresses:           ips,
+	}
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func (c *testCert) tlsCertificate(t *testing.T) *tls.Certificate {
+	t.Helper()
+
+	cert, err := tls.X509KeyPair(c.certPEM, c.keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &cert
+}
+
+// writeRotated writes content to path with a modification time after the previous one, so the rotation is seen
+// even within the resolution of the file system clock
+func writeRotated(t *testing.T, path string, content []byte, generation int) {
+	t.Helper()
+
+	err := ioutil.WriteFile(path, content, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modTime := time.Now().Add(time.Duration(generation) * time.Minute)
+	err = os.Chtimes(path, modTime, modTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTargetClientTLSRotation(t *testing.T) {
+	loopback := net.ParseIP("127.0.0.1")
+
+	serverCA1 := newTestCert(t, "server-ca-1", nil, true)
+	serverCA2 := newTestCert(t, "server-ca-2", nil, true)
+	clientCA := newTestCert(t, "client-ca", nil, true)
+
+	var mtx sync.Mutex
+	serverCert := newTestCert(t, "beat", serverCA1, false, loopback).tlsCertificate(t)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCA.cert)
+
+	// the server answers with the common name of the client certificate of the connection
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	// StartTLS would set its own certificate, which takes precedence over GetCertificate for IP addresses
+	server.Listener = tls.NewListener(server.Listener, &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			mtx.Lock()
+			defer mtx.Unlock()
+			return serverCert, nil
+		},
+	})
+	// every request is a new handshake, connections opened before a rotation are not affected by it
+	server.Config.SetKeepAlivesEnabled(false)
+	server.Start()
+	defer server.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+
+	client1 := newTestCert(t, "client-1", clientCA, false)
+	writeRotated(t, caFile, serverCA1.certPEM, 0)
+	writeRotated(t, certFile, client1.certPEM, 0)
+	writeRotated(t, keyFile, client1.keyPEM, 0)
+
+	httpClient, parsedURL, err := newTargetClient(config.Target{
+		URI:     strings.Replace(server.URL, "http://", "https://", 1),
+		Timeout: 5 * time.Second,
+		TLS: config.TLSConfig{
+			CAFile:   caFile,
+			CertFile: certFile,
+			KeyFile:  keyFile,
+		},
+	})
+	if err != nil {
+		t.Fatalf("newTargetClient: %v", err)
+	}
+
+	get := func() (string, error) {
+		response, err := httpClient.Get(parsedURL.String())
+		if err != nil {
+			return "", err
+		}
+		defer response.Body.Close()
+
+		body, err := ioutil.ReadAll(response.Body)
+		return string(body), err
+	}
+
+	commonName, err := get()
+	if err != nil || commonName != "client-1" {
+		t.Fatalf("initial request: got %q, %v, want client-1", commonName, err)
+	}
+
+	// the server moves to a certificate of a new CA, the client trusts it once the CA file is rotated
+	mtx.Lock()
+	serverCert = newTestCert(t, "beat", serverCA2, false, loopback).tlsCertificate(t)
+	mtx.Unlock()
+
+	_, err = get()
+	if err == nil {
+		t.Fatal("expected the certificate of the new CA to be rejected before the CA file is rotated")
+	}
+
+	writeRotated(t, caFile, serverCA2.certPEM, 1)
+
+	commonName, err = get()
+	if err != nil || commonName != "client-1" {
+		t.Fatalf("request after CA rotation: got %q, %v, want client-1", commonName, err)
+	}
+
+	// a rotated client certificate is sent on the next handshake
+	client2 := newTestCert(t, "client-2", clientCA, false)
+	writeRotated(t, certFile, client2.certPEM, 2)
+	writeRotated(t, keyFile, client2.keyPEM, 2)
+
+	commonName, err = get()
+	if err != nil || commonName != "client-2" {
+		t.Fatalf("request after client certificate rotation: got %q, %v, want client-2", commonName, err)
+	}
+}
+
+func TestTargetClientTLSServerName(t *testing.T) {
+	ca := newTestCert(t, "server-ca", nil, true)
+	serverCert := newTestCert(t, "beat", ca, false, net.ParseIP("127.0.0.1"))
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{*serverCert.tlsCertificate(t)}}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	writeRotated(t, caFile, ca.certPEM, 0)
+
+	for _, test := range []struct {
+		serverName string
+		valid      bool
+	}{
+		{serverName: "", valid: true},
+		{serverName: "beat.example.com", valid: false},
+	} {
+		httpClient, parsedURL, err := newTargetClient(config.Target{
+			URI:     server.URL,
+			Timeout: 5 * time.Second,
+			TLS:     config.TLSConfig{CAFile: caFile, ServerName: test.serverName},
+		})
+		if err != nil {
+			t.Fatalf("newTargetClient: %v", err)
+		}
+
+		response, err := httpClient.Get(parsedURL.String())
+		if err == nil {
+			response.Body.Close()
+		}
+		if (err == nil) != test.valid {
+			t.Errorf("server name %q: got error %v, expected valid %v", test.serverName, err, test.valid)
+		}
+	}
+}